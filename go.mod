@@ -3,14 +3,14 @@ module github.com/2456868764/go-learning
 go 1.19
 
 require (
+	github.com/blang/semver/v4 v4.0.0
+	github.com/hashicorp/go-version v1.6.0
 	github.com/stretchr/testify v1.8.1
 	golang.org/x/sync v0.1.0
 )
 
 require (
-	github.com/blang/semver/v4 v4.0.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/hashicorp/go-version v1.6.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
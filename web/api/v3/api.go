@@ -3,7 +3,7 @@ package v3
 import (
 	"fmt"
 	"github.com/2456868764/go-learning/web/pkg/engine"
-	"strconv"
+	"net/http"
 )
 
 func GetUserAgent(c *engine.Context) {
@@ -25,7 +25,7 @@ func GetHeaders(c *engine.Context) {
 }
 
 func GetUserProfile(c *engine.Context) {
-	userId, err := strconv.Atoi(c.PathParams["userId"])
+	userId, err := c.ParamInt("userId")
 	if err != nil {
 		c.ServerErrorJson("can not find user id")
 		return
@@ -44,3 +44,30 @@ type UserProfile struct {
 	Age int  `json:"age"`
 }
 
+
+func GetCookies(c *engine.Context) {
+	cookies := make(map[string]string)
+	for _, cookie := range c.R.Cookies() {
+		// 和 SetCookies 对称，读取 url 解码后的值
+		value, err := c.Cookie(cookie.Name)
+		if err != nil {
+			value = cookie.Value
+		}
+		cookies[cookie.Name] = value
+	}
+	c.OKJson(map[string]any{"cookies": cookies})
+}
+
+func SetCookies(c *engine.Context) {
+	for name, values := range c.R.URL.Query() {
+		c.SetCookie(name, values[0], 0, "/", "", false, false)
+	}
+	c.Redirect(http.StatusFound, "/cookies")
+}
+
+func DeleteCookies(c *engine.Context) {
+	for name := range c.R.URL.Query() {
+		c.SetCookie(name, "", -1, "/", "", false, false)
+	}
+	c.Redirect(http.StatusFound, "/cookies")
+}
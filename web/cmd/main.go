@@ -1,15 +1,46 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"log"
+	"time"
+
 	v3 "github.com/2456868764/go-learning/web/api/v3"
 	"github.com/2456868764/go-learning/web/pkg/engine"
+	"github.com/2456868764/go-learning/web/pkg/middleware/requestid"
+	"github.com/2456868764/go-learning/web/pkg/openapi"
+	"github.com/2456868764/go-learning/web/pkg/routeconfig"
 )
 
+var routesFile = flag.String("routes", "cmd/routes.yaml", "route config file, reloaded on change")
+var debug = flag.Bool("debug", false, "print the route table at startup and serve /debug/routes")
+
 func main() {
+	flag.Parse()
 	engine := engine.New()
-	engine.GET("/headers", v3.GetHeaders)
-	engine.GET("/ip", v3.GetIP)
-	engine.GET("/user-agent", v3.GetUserAgent)
-	engine.GET("/user/:userId/profile", v3.GetUserProfile)
+	engine.Debug = *debug
+
+	// 配置文件通过名字引用这里登记的处理函数和中间件
+	registry := routeconfig.NewRegistry().
+		Handler("headers", v3.GetHeaders).
+		Handler("ip", v3.GetIP).
+		Handler("userAgent", v3.GetUserAgent).
+		Handler("userProfile", v3.GetUserProfile).
+		Handler("cookies", v3.GetCookies).
+		Handler("setCookies", v3.SetCookies).
+		Handler("deleteCookies", v3.DeleteCookies).
+		Middleware("requestid", requestid.RequestID())
+	loader := routeconfig.NewLoader(engine, registry, *routesFile)
+	if err := loader.Load(); err != nil {
+		log.Fatal(err)
+	}
+	go loader.Watch(context.Background(), 2*time.Second)
+
+	// 路由表会暴露内部接口，只在调试时开启
+	if *debug {
+		engine.GET("/debug/routes", engine.RoutesHandler())
+	}
+	openapi.Register(engine, openapi.Options{Info: openapi.Info{Title: "httpbin"}})
 	engine.Run(":8080")
 }
@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrorRequestBodyTooLarge 请求体超过大小限制
+var ErrorRequestBodyTooLarge = errors.New("request body too large")
+
+// handleRequestBody 处理链第一个处理函数，负责请求体大小限制和 Content-Encoding 解压
+// 限制作用在解压后的数据上，防止压缩炸弹
+func (e *Engine) handleRequestBody(c *Context) {
+	r := c.R
+	if r.Body == nil || r.Body == http.NoBody {
+		return
+	}
+
+	limit := e.MaxBodyBytes
+	if c.route != nil && c.route.MaxBodyBytes != 0 {
+		limit = c.route.MaxBodyBytes
+	}
+
+	// 请求头已经声明超过限制，直接拒绝，不用读请求体
+	if limit > 0 && r.ContentLength > limit {
+		c.StringFormat(http.StatusRequestEntityTooLarge, "Request Entity Too Large: limit %d bytes", limit)
+		c.Abort()
+		return
+	}
+
+	switch strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			c.StringFormat(http.StatusBadRequest, "Bad Request: invalid gzip body")
+			c.Abort()
+			return
+		}
+		r.Body = &gzipBody{Reader: reader, body: r.Body}
+		// 解压后长度未知，同时去掉编码头，后面处理函数看到的就是原始数据
+		r.Header.Del("Content-Encoding")
+		r.ContentLength = -1
+	default:
+		c.StringFormat(http.StatusUnsupportedMediaType, "Unsupported Content-Encoding: %s", r.Header.Get("Content-Encoding"))
+		c.Abort()
+		return
+	}
+
+	if limit > 0 {
+		c.limitBody(limit)
+	}
+}
+
+// limitBody 限制请求体大小，读取时超过限制返回 *http.MaxBytesError，
+// 不管是 Content-Length 未知还是通过 ReadJsonObject、PostForm 等哪个方法读取，响应都是 413
+func (c *Context) limitBody(limit int64) {
+	c.R.Body = http.MaxBytesReader(c.W, c.R.Body, limit)
+	if c.writer != nil {
+		c.R.Body = &limitedBody{ReadCloser: c.R.Body, writer: c.writer}
+	}
+}
+
+// limitedBody 读取到 *http.MaxBytesError 时标记响应改为 413
+type limitedBody struct {
+	io.ReadCloser
+	writer *responseWriter
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxBytesError *http.MaxBytesError
+	if err != nil && errors.As(err, &maxBytesError) {
+		b.writer.bodyTooLarge = true
+	}
+	return n, err
+}
+
+// writeBodyTooLarge 请求体超过限制并且处理函数没有写响应时返回 413
+func (c *Context) writeBodyTooLarge() {
+	if c.writer != nil && c.writer.bodyTooLarge && c.writer.status == 0 {
+		c.StringFormat(http.StatusRequestEntityTooLarge, "Request Entity Too Large")
+	}
+}
+
+// gzipBody 解压请求体，关闭时同时关闭原始请求体
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (g *gzipBody) Close() error {
+	_ = g.Reader.Close()
+	return g.body.Close()
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type bodyMessage struct {
+	Message string `json:"message"`
+}
+
+func gzipBytes(t *testing.T, data string) []byte {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	_, err := writer.Write([]byte(data))
+	assert.Nil(t, err)
+	assert.Nil(t, writer.Close())
+	return buf.Bytes()
+}
+
+func TestEngine_MaxBodyBytes(t *testing.T) {
+	e := New()
+	e.MaxBodyBytes = 16
+	var readErr error
+	handler := func(c *Context) {
+		msg := &bodyMessage{}
+		readErr = c.ReadJsonObject(msg)
+		if readErr != nil {
+			c.StringFormat(http.StatusBadRequest, "%v", readErr)
+			return
+		}
+		c.StringOk(msg.Message)
+	}
+	e.POST("/echo", handler)
+	e.POST("/large", handler, WithMaxBodyBytes(1024))
+
+	// Content-Length 超过限制直接 413
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(`{"message":"hello world"}`)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	// 路由级别限制覆盖全局限制
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/large", strings.NewReader(`{"message":"hello world"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "hello world", w.Body.String())
+
+	// 没有 Content-Length 时读取过程中超过限制
+	req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(`{"message":"hello world"}`))
+	req.ContentLength = -1
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, ErrorRequestBodyTooLarge, readErr)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	// 表单读取超过限制也返回 413，处理函数没有写响应时同样返回 413
+	e.POST("/form", func(c *Context) {
+		c.StringOk(c.PostForm("name"))
+	})
+	e.POST("/ignore", func(c *Context) {
+		_, _ = io.ReadAll(c.R.Body)
+	})
+	for _, path := range []string{"/form", "/ignore"} {
+		req = httptest.NewRequest(http.MethodPost, path, strings.NewReader("name="+strings.Repeat("a", 64)))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.ContentLength = -1
+		w = httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, path)
+	}
+}
+
+func TestEngine_GzipRequestBody(t *testing.T) {
+	e := New()
+	e.MaxBodyBytes = 64
+	e.POST("/echo", func(c *Context) {
+		msg := &bodyMessage{}
+		if err := c.ReadJsonObject(msg); err != nil {
+			c.StringFormat(http.StatusBadRequest, "%v", err)
+			return
+		}
+		c.StringOk(msg.Message)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(gzipBytes(t, `{"message":"hello"}`)))
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "hello", w.Body.String())
+
+	// 限制作用在解压后的数据上
+	req = httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(gzipBytes(t, `{"message":"`+strings.Repeat("a", 1024)+`"}`)))
+	req.Header.Set("Content-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Equal(t, ErrorRequestBodyTooLarge.Error(), w.Body.String())
+
+	req = httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader("not gzip"))
+	req.Header.Set("Content-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader("{}"))
+	req.Header.Set("Content-Encoding", "br")
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
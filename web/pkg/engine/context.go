@@ -1,13 +1,20 @@
 package engine
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"net/url"
 	"sync"
 )
 
+// abortIndex 处理链中断后 index 的位置
+const abortIndex = math.MaxInt32 / 2
+
 type Context struct {
 
 	W http.ResponseWriter
@@ -18,8 +25,30 @@ type Context struct {
 	mu sync.RWMutex
 	// 每个请求独立上下文传值用
 	Keys map[string]any
+	// 类型化 key 的值，参考 Key
+	typedKeys map[*keyID]any
 	//路由匹配数据
 	PathParams map[string]string
+
+	// 处理链，依次是全局中间件，路由中间件和路由处理函数
+	handlers []HandlerFunc
+	// 处理链当前执行位置
+	index int
+	// 命中的路由，没有命中为 nil
+	route *Route
+	engine *Engine
+	// 查询参数缓存，第一次读取查询参数时解析
+	queryCache url.Values
+	// 记录响应状态码和大小，Engine.ServeHTTP 创建
+	writer *responseWriter
+	// multipart 表单解析或者校验失败的错误，MultipartForm 再次调用时返回
+	multipartErr error
+
+	// 客户端断开或者服务关闭时关闭，Done 第一次调用时创建
+	done     <-chan struct{}
+	doneOnce sync.Once
+	// 处理链执行完时关闭，用于结束 Done 的监听协程，只有 Engine.ServeHTTP 会关闭
+	finished chan struct{}
 }
 
 func NewContext(w http.ResponseWriter, r *http.Request) *Context {
@@ -27,6 +56,7 @@ func NewContext(w http.ResponseWriter, r *http.Request) *Context {
 		W: w,
 		R: r,
 		PathParams: make(map[string]string),
+		index: -1,
 	}
 	if r!= nil {
 		context.Method = r.Method
@@ -35,13 +65,136 @@ func NewContext(w http.ResponseWriter, r *http.Request) *Context {
 	return context
 }
 
-func (c *Context) ReadJsonObject(object any) error {
-	body, err := io.ReadAll(c.R.Body)
-	if err!= nil {
-		return err
+// Next 执行处理链中后续的处理函数，只能在中间件里调用
+func (c *Context) Next() {
+	c.index++
+	for c.index < len(c.handlers) {
+		c.handlers[c.index](c)
+		c.index++
+	}
+}
+
+// Abort 中断处理链，后续处理函数不再执行，当前处理函数会继续执行完
+func (c *Context) Abort() {
+	c.index = abortIndex
+}
+
+// IsAborted 处理链是否被中断
+func (c *Context) IsAborted() bool {
+	return c.index >= abortIndex
+}
+
+// AbortWithStatus 写入状态码并中断处理链
+func (c *Context) AbortWithStatus(code int) {
+	c.Status(code)
+	c.Abort()
+}
+
+// Done 返回一个 channel，客户端断开连接或者服务关闭时关闭，长连接处理函数用来判断何时退出
+// NewContext 直接创建的 Context 没有 Engine，直接返回请求 Context 的 Done，不启动监听协程
+func (c *Context) Done() <-chan struct{} {
+	c.doneOnce.Do(func() {
+		if c.engine == nil {
+			c.done = c.R.Context().Done()
+			return
+		}
+		done := make(chan struct{})
+		c.done = done
+		c.finished = make(chan struct{})
+		shutdown := c.engine.shutdown
+		go func() {
+			defer close(done)
+			select {
+			case <-c.R.Context().Done():
+			case <-shutdown:
+			case <-c.finished:
+			}
+		}()
+	})
+	return c.done
+}
+
+// finish 处理链执行完成
+func (c *Context) finish() {
+	if c.finished != nil {
+		close(c.finished)
 	}
+}
 
-	return json.Unmarshal(body, object)
+// FullPath 返回命中路由的注册模式，比如 /user/:userId/profile，没有命中返回空字符串
+func (c *Context) FullPath() string {
+	if c.route == nil {
+		return ""
+	}
+	return c.route.Pattern
+}
+
+// ResponseStatus 返回已经写入的响应状态码，还没有写入返回 0
+func (c *Context) ResponseStatus() int {
+	if c.writer == nil {
+		return 0
+	}
+	return c.writer.status
+}
+
+// ResponseSize 返回已经写入的响应体字节数
+func (c *Context) ResponseSize() int {
+	if c.writer == nil {
+		return 0
+	}
+	return c.writer.size
+}
+
+// Written 响应状态码是否已经写入
+func (c *Context) Written() bool {
+	return c.ResponseStatus() != 0
+}
+
+// Engine 返回处理请求的 Engine，NewContext 直接创建的 Context 返回 nil
+func (c *Context) Engine() *Engine {
+	return c.engine
+}
+
+// Route 返回命中的路由，没有命中返回 nil
+func (c *Context) Route() *Route {
+	return c.route
+}
+
+// RouteMeta 返回命中路由的元数据
+func (c *Context) RouteMeta(key string) (value any, exists bool) {
+	if c.route == nil {
+		return nil, false
+	}
+	value, exists = c.route.Meta[key]
+	return
+}
+
+// serveRoute 把命中路由的中间件和处理函数追加到处理链，然后执行
+func (c *Context) serveRoute(route *Route) {
+	c.route = route
+	c.handlers = append(c.handlers, route.Middlewares...)
+	c.handlers = append(c.handlers, route.Handler)
+	if route.Timeout <= 0 {
+		c.Next()
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.R.Context(), route.Timeout)
+	defer cancel()
+	c.R = c.R.WithContext(ctx)
+	c.Next()
+	if ctx.Err() == context.DeadlineExceeded && c.writer != nil && c.writer.status == 0 {
+		c.StringFormat(http.StatusServiceUnavailable, "Service Unavailable: handler timeout")
+	}
+}
+
+// ReadJsonObject 流式解码请求体 JSON，请求体超过大小限制返回 ErrorRequestBodyTooLarge
+func (c *Context) ReadJsonObject(object any) error {
+	err := json.NewDecoder(c.R.Body).Decode(object)
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return ErrorRequestBodyTooLarge
+	}
+	return err
 }
 
 func (c *Context) ReponseJson(httpStatus int, object any) error {
@@ -77,13 +230,45 @@ func (c *Context) PostForm(key string) string {
 }
 
 func (c *Context) Query(key string) string {
-	return c.R.URL.Query().Get(key)
+	value, _ := c.GetQuery(key)
+	return value
 }
 
 func (c *Context) GetHeader(key string) string {
 	return c.R.Header.Get(key)
 }
 
+// Cookie 返回请求中名字为 name 的 cookie 值，值会做 url 解码，不存在返回 http.ErrNoCookie
+func (c *Context) Cookie(name string) (string, error) {
+	cookie, err := c.R.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return url.QueryUnescape(cookie.Value)
+}
+
+// SetCookie 在响应中添加 Set-Cookie 头，值会做 url 编码，必须在写入状态码前调用
+// maxAge 等于 0 表示会话 cookie，小于 0 表示删除 cookie
+func (c *Context) SetCookie(name string, value string, maxAge int, path string, domain string, secure bool, httpOnly bool) {
+	if path == "" {
+		path = "/"
+	}
+	http.SetCookie(c.W, &http.Cookie{
+		Name:     name,
+		Value:    url.QueryEscape(value),
+		MaxAge:   maxAge,
+		Path:     path,
+		Domain:   domain,
+		Secure:   secure,
+		HttpOnly: httpOnly,
+	})
+}
+
+// Redirect 重定向到 location
+func (c *Context) Redirect(code int, location string) {
+	http.Redirect(c.W, c.R, location, code)
+}
+
 func (c *Context) Status(code int) {
 	c.W.WriteHeader(code)
 }
@@ -1,50 +1,133 @@
 package engine
 
 import (
+	"context"
 	"net/http"
+	"sync"
+	"sync/atomic"
 )
 
 
 // Routable 可以路由
 type Routable interface {
-	// AddRoute 添加一个路由，命中该路由的调用 handlerFunc 代码
-	AddRoute(method string, pattern string, handlerFunc HandlerFunc) error
+	// AddRoute 添加一个路由，命中该路由的调用 handlerFunc 代码, opts 设置路由级别配置
+	AddRoute(method string, pattern string, handlerFunc HandlerFunc, opts ...RouteOption) error
 }
 
 // HandlerFunc 某个路由对应具体执行
 type HandlerFunc func(c *Context)
 
 type Engine struct {
-	router Router
+	// 路由快照，添加删除路由时整体替换
+	table atomic.Pointer[routeTable]
+	// 串行化路由修改
+	routesMu sync.Mutex
+	// Engine.Host 返回的注册入口
+	hostHandles map[string]*Host
+	// MaxBodyBytes 请求体大小限制，超过返回 413，0 表示不限制，可以被路由 WithMaxBodyBytes 覆盖
+	MaxBodyBytes int64
+	// Upload 文件上传配置，可以被路由 WithUploadOptions 覆盖
+	Upload UploadOptions
+	// Debug 调试模式，Run 启动时打印路由表
+	Debug bool
+	// RouterOptions 末尾 /、路径规范化和大小写匹配配置
+	RouterOptions RouterOptions
+
+	// serverMu 保护 server，Run 和 Shutdown 可能在不同协程调用
+	serverMu sync.Mutex
+	server   *http.Server
+	// 服务关闭时关闭，通知 SSE 等长连接处理函数退出
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
 }
 
 func New() *Engine {
 	engine := &Engine{
-		//router: NewMapBasedRouter(),
-		router: NewTreeBasedRouter(),
+		shutdown: make(chan struct{}),
+		Upload:   UploadOptions{MaxMemory: defaultMultipartMemory},
 	}
+	//engine.table.Store(newRouteTable(NewMapBasedRouter()))
+	engine.table.Store(newRouteTable(NewTreeBasedRouter()))
 	return engine
 }
 
 func (e *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	c := NewContext(w, r)
-	e.router.ServerHTTP(c)
+	writer := &responseWriter{ResponseWriter: w}
+	c := NewContext(writer, r)
+	c.engine = e
+	c.writer = writer
+	// 整个请求使用同一个路由快照，包括全局中间件
+	table := e.table.Load()
+	// 请求体处理放在处理链最前面，然后是全局中间件，路由器再追加命中路由的中间件和处理函数
+	c.handlers = make([]HandlerFunc, 0, len(table.middlewares)+4)
+	c.handlers = append(c.handlers, e.handleRequestBody)
+	c.handlers = append(c.handlers, table.middlewares...)
+	if e.RouterOptions.CleanPath {
+		if cleaned := cleanPath(c.Path); cleaned != c.Path {
+			redirectTo(c, cleaned)
+			c.finish()
+			return
+		}
+	}
+	if route := table.findMount(c.Path); route != nil {
+		c.serveRoute(route)
+	} else {
+		table.routerFor(c).ServerHTTP(c)
+	}
+	c.writeBodyTooLarge()
+	c.finish()
+}
+
+// Use 添加全局中间件，中间件按添加顺序执行，和路由一样保存在快照里，可以在处理请求的同时调用
+func (e *Engine) Use(middlewares ...HandlerFunc) {
+	_ = e.updateRoutes(func(t *routeTable) error {
+		t.middlewares = append(t.middlewares, middlewares...)
+		return nil
+	})
 }
 
-func (e *Engine) AddRoute(method string, pattern string, handler HandlerFunc) error {
-	e.router.AddRoute(method, pattern, handler)
-	return nil
+// AddRoute 在默认路由树上添加路由，可以在处理请求的同时调用
+func (e *Engine) AddRoute(method string, pattern string, handler HandlerFunc, opts ...RouteOption) error {
+	return e.addRoute(nil, method, pattern, handler, opts...)
 }
 
-func (e *Engine) GET(pattern string, handler HandlerFunc) {
-	e.AddRoute(http.MethodGet, pattern, handler)
+func (e *Engine) GET(pattern string, handler HandlerFunc, opts ...RouteOption) {
+	e.AddRoute(http.MethodGet, pattern, handler, opts...)
 }
 
-func (e *Engine) POST(pattern string, handler HandlerFunc) {
-	e.AddRoute(http.MethodPost, pattern, handler)
+func (e *Engine) POST(pattern string, handler HandlerFunc, opts ...RouteOption) {
+	e.AddRoute(http.MethodPost, pattern, handler, opts...)
 }
 
 func (e *Engine) Run(addr string) error {
-	err := http.ListenAndServe(addr, e)
-	return err
+	if e.Debug {
+		e.printRoutes()
+	}
+	e.serverMu.Lock()
+	// 已经 Shutdown 时不再启动
+	select {
+	case <-e.shutdown:
+		e.serverMu.Unlock()
+		return http.ErrServerClosed
+	default:
+	}
+	server := &http.Server{Addr: addr, Handler: e}
+	e.server = server
+	e.serverMu.Unlock()
+	return server.ListenAndServe()
+}
+
+// Shutdown 优雅关闭，先通知长连接处理函数退出，再等待正在处理的请求完成
+// 在 Run 之前调用时之后的 Run 直接返回 http.ErrServerClosed
+func (e *Engine) Shutdown(ctx context.Context) error {
+	e.serverMu.Lock()
+	e.shutdownOnce.Do(func() {
+		close(e.shutdown)
+	})
+	server := e.server
+	e.serverMu.Unlock()
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
 }
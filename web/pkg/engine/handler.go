@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+var ErrorInvalidMountPrefix = errors.New("invalid mount prefix")
+
+// anyMethod 挂载路由的方法，表示匹配所有方法
+const anyMethod = "*"
+
+// WrapH 把 http.Handler 包装成 HandlerFunc
+func WrapH(handler http.Handler) HandlerFunc {
+	return func(c *Context) {
+		handler.ServeHTTP(c.W, c.R)
+	}
+}
+
+// WrapF 把 http.HandlerFunc 包装成 HandlerFunc
+func WrapF(handler http.HandlerFunc) HandlerFunc {
+	return WrapH(handler)
+}
+
+// WrapMiddleware 把标准库风格的 func(http.Handler) http.Handler 中间件包装成引擎中间件
+// 中间件调用 next 时继续执行处理链，可以替换 ResponseWriter 和 Request，没有调用 next 时中断处理链
+func WrapMiddleware(middleware func(http.Handler) http.Handler) HandlerFunc {
+	return func(c *Context) {
+		called := false
+		w, r := c.W, c.R
+		next := http.HandlerFunc(func(nw http.ResponseWriter, nr *http.Request) {
+			called = true
+			c.W, c.R = nw, nr
+			c.Next()
+			c.W = w
+		})
+		middleware(next).ServeHTTP(w, r)
+		if !called {
+			c.Abort()
+		}
+	}
+}
+
+// Handle 注册 http.Handler
+func (e *Engine) Handle(method string, pattern string, handler http.Handler, opts ...RouteOption) error {
+	return e.AddRoute(method, pattern, WrapH(handler), opts...)
+}
+
+// Any 为所有支持的方法注册同一个处理函数
+func (e *Engine) Any(pattern string, handler HandlerFunc, opts ...RouteOption) error {
+	for _, method := range supportedMethods {
+		if err := e.AddRoute(method, pattern, handler, opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Mount 把 prefix 开头的请求交给 handler 处理，转发前去掉路径前缀，handler 可以是另一个 Engine
+// 比如 Mount("/debug/pprof", mux) 时 /debug/pprof/heap 转发给 mux 的路径为 /heap
+// 挂载优先于路由匹配，多个挂载前缀按最长前缀匹配，全局中间件仍然执行
+func (e *Engine) Mount(prefix string, handler http.Handler, opts ...RouteOption) error {
+	prefix = strings.TrimRight(prefix, "/")
+	if prefix == "" || prefix[0] != '/' {
+		return ErrorInvalidMountPrefix
+	}
+	route := newRoute(anyMethod, prefix+"/*", stripPrefix(prefix, handler), opts...)
+	return e.updateRoutes(func(t *routeTable) error {
+		if err := t.checkName(route); err != nil {
+			return err
+		}
+		t.mounts = append(t.mounts, route)
+		// 长的前缀排前面，先匹配
+		sort.SliceStable(t.mounts, func(i, j int) bool {
+			return len(t.mounts[i].Pattern) > len(t.mounts[j].Pattern)
+		})
+		return t.registerName(route)
+	})
+}
+
+// stripPrefix 和 http.StripPrefix 一样，区别是剩余路径为空时转发 /
+func stripPrefix(prefix string, handler http.Handler) HandlerFunc {
+	return func(c *Context) {
+		r := c.R
+		path := strings.TrimPrefix(r.URL.Path, prefix)
+		rawPath := strings.TrimPrefix(r.URL.RawPath, prefix)
+		if path == "" {
+			path = "/"
+		}
+		if r.URL.RawPath != "" && rawPath == "" {
+			rawPath = "/"
+		}
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = path
+		r2.URL.RawPath = rawPath
+		handler.ServeHTTP(c.W, r2)
+	}
+}
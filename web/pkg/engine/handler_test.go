@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_Handle(t *testing.T) {
+	e := New()
+	assert.Nil(t, e.Handle(http.MethodGet, "/healthz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})))
+	e.GET("/legacy", WrapF(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	assert.Nil(t, e.Any("/any", func(c *Context) {
+		c.StringOk(c.Method)
+	}, WithName("any")))
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, "ok", w.Body.String())
+
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/legacy", nil))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	for _, method := range supportedMethods {
+		w = httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(method, "/any", nil))
+		assert.Equal(t, method, w.Body.String())
+	}
+	url, err := e.URL("any")
+	assert.Nil(t, err)
+	assert.Equal(t, "/any", url)
+}
+
+func TestEngine_Mount(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "mux:"+r.URL.Path)
+	})
+	sub := New()
+	sub.GET("/user/:id", func(c *Context) {
+		c.StringOk("sub:" + c.PathParams["id"])
+	})
+
+	e := New()
+	e.Use(func(c *Context) {
+		c.SetHeader("X-Global", "1")
+	})
+	e.GET("/api/v1/other", func(c *Context) {
+		c.StringOk("engine")
+	})
+	assert.Nil(t, e.Mount("/legacy/", mux))
+	assert.Nil(t, e.Mount("/api", sub))
+	assert.Nil(t, e.Mount("/api/v1", mux))
+	assert.Equal(t, ErrorInvalidMountPrefix, e.Mount("/", mux))
+	assert.Equal(t, ErrorInvalidMountPrefix, e.Mount("legacy", mux))
+
+	testCases := []struct {
+		path     string
+		wantBody string
+	}{
+		{path: "/legacy/a/b", wantBody: "mux:/a/b"},
+		{path: "/legacy", wantBody: "mux:/"},
+		{path: "/api/user/42", wantBody: "sub:42"},
+		// 最长前缀优先，挂载优先于路由
+		{path: "/api/v1/other", wantBody: "mux:/other"},
+		{path: "/legacyx", wantBody: "Not Found Method: GET Path: /legacyx"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			assert.Equal(t, tc.wantBody, w.Body.String())
+			assert.Equal(t, "1", w.Header().Get("X-Global"))
+		})
+	}
+
+	routes := e.Routes()
+	assert.Equal(t, 4, len(routes))
+	assert.Equal(t, "*", routes[3].Method)
+	assert.Equal(t, "/legacy/*", routes[3].Pattern)
+}
+
+func TestWrapMiddleware(t *testing.T) {
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("X-Auth", "1")
+			next.ServeHTTP(w, r.WithContext(r.Context()))
+		})
+	}
+	after := false
+	e := New()
+	e.Use(WrapMiddleware(auth), func(c *Context) {
+		after = true
+	})
+	e.GET("/", func(c *Context) {
+		c.StringOk("ok")
+	})
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.False(t, after)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "token")
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, "ok", w.Body.String())
+	assert.Equal(t, "1", w.Header().Get("X-Auth"))
+	assert.True(t, after)
+}
@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var ErrorInvalidHostPattern = errors.New("invalid host pattern")
+
+// Host 一个 Host 模式的路由注册入口，通过 Engine.Host 创建
+type Host struct {
+	engine  *Engine
+	pattern string
+	// 按 . 分割的 host 模式，比如 [:tenant example com]
+	labels []string
+	// 静态 label 数量，越多越优先匹配
+	static int
+	err    error
+}
+
+// Host 返回 pattern 对应的路由注册入口，同一个 pattern 返回同一个 Host
+// pattern 支持完全匹配 api.example.com，通配子域名 *.example.com 和参数 :tenant.example.com
+// * 和 :param 只匹配一段 label，参数值写入 Context.PathParams
+// 请求 Host 优先完全匹配，其次按静态 label 多的优先，都不匹配时使用 Engine 默认路由树
+func (e *Engine) Host(pattern string) *Host {
+	pattern = normalizeHost(pattern)
+	e.routesMu.Lock()
+	defer e.routesMu.Unlock()
+	if h, ok := e.hostHandles[pattern]; ok {
+		return h
+	}
+	h := &Host{
+		engine:  e,
+		pattern: pattern,
+		labels:  strings.Split(pattern, "."),
+	}
+	for _, label := range h.labels {
+		switch {
+		case label == "" || label == ":":
+			h.err = ErrorInvalidHostPattern
+		case label == "*" || strings.HasPrefix(label, ":"):
+		default:
+			h.static++
+		}
+	}
+	if e.hostHandles == nil {
+		e.hostHandles = make(map[string]*Host)
+	}
+	e.hostHandles[pattern] = h
+	return h
+}
+
+// AddRoute 在 Host 路由树上添加路由，pattern 无效时返回 ErrorInvalidHostPattern
+func (h *Host) AddRoute(method string, pattern string, handler HandlerFunc, opts ...RouteOption) error {
+	return h.engine.addRoute(h, method, pattern, handler, opts...)
+}
+
+func (h *Host) GET(pattern string, handler HandlerFunc, opts ...RouteOption) {
+	h.AddRoute(http.MethodGet, pattern, handler, opts...)
+}
+
+func (h *Host) POST(pattern string, handler HandlerFunc, opts ...RouteOption) {
+	h.AddRoute(http.MethodPost, pattern, handler, opts...)
+}
+
+// Handle 注册 http.Handler
+func (h *Host) Handle(method string, pattern string, handler http.Handler, opts ...RouteOption) error {
+	return h.AddRoute(method, pattern, WrapH(handler), opts...)
+}
+
+// Any 为所有支持的方法注册同一个处理函数
+func (h *Host) Any(pattern string, handler HandlerFunc, opts ...RouteOption) error {
+	for _, method := range supportedMethods {
+		if err := h.AddRoute(method, pattern, handler, opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeHost 去掉末尾的 . 并转成小写
+func normalizeHost(host string) string {
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// stripPort 去掉请求 Host 里的端口，IPv6 地址带方括号
+func stripPort(host string) string {
+	if i := strings.LastIndexByte(host, ':'); i != -1 && i > strings.LastIndexByte(host, ']') {
+		return host[:i]
+	}
+	return host
+}
@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_Host(t *testing.T) {
+	e := New()
+	e.GET("/", func(c *Context) {
+		c.StringOk("default")
+	})
+	e.Host("API.example.com").GET("/", func(c *Context) {
+		c.StringOk("api")
+	})
+	e.Host("*.example.com").GET("/", func(c *Context) {
+		c.StringOk("wildcard")
+	})
+	e.Host(":tenant.example.com").GET("/user/:id", func(c *Context) {
+		c.StringOk(c.PathParams["tenant"] + ":" + c.PathParams["id"])
+	})
+	e.Host(":tenant.admin.example.com").GET("/", func(c *Context) {
+		c.StringOk("admin:" + c.PathParams["tenant"])
+	})
+	assert.Same(t, e.Host("api.example.com"), e.Host("api.example.com."))
+	assert.Equal(t, ErrorInvalidHostPattern, e.Host("a..com").AddRoute(http.MethodGet, "/", func(c *Context) {}))
+
+	testCases := []struct {
+		host     string
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{host: "api.example.com", path: "/", wantCode: http.StatusOK, wantBody: "api"},
+		{host: "API.Example.com:8080", path: "/", wantCode: http.StatusOK, wantBody: "api"},
+		// * 和 :tenant 都能匹配，先注册的优先
+		{host: "foo.example.com", path: "/", wantCode: http.StatusOK, wantBody: "wildcard"},
+		{host: "foo.example.com", path: "/user/42", wantCode: http.StatusNotFound},
+		{host: "foo.admin.example.com", path: "/", wantCode: http.StatusOK, wantBody: "admin:foo"},
+		{host: "example.com", path: "/", wantCode: http.StatusOK, wantBody: "default"},
+		{host: "a.b.c.example.com", path: "/", wantCode: http.StatusOK, wantBody: "default"},
+		{host: "[::1]:8080", path: "/", wantCode: http.StatusOK, wantBody: "default"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.host+tc.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Host = tc.host
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+			assert.Equal(t, tc.wantCode, w.Code)
+			if tc.wantBody != "" {
+				assert.Equal(t, tc.wantBody, w.Body.String())
+			}
+		})
+	}
+
+	routes := e.Routes()
+	assert.Equal(t, 5, len(routes))
+	assert.Equal(t, "", routes[0].Host)
+	assert.Equal(t, "*.example.com", routes[1].Host)
+	assert.Equal(t, ":tenant.admin.example.com", routes[2].Host)
+}
+
+func TestEngine_HostParam(t *testing.T) {
+	e := New()
+	e.Host(":tenant.example.com").GET("/user/:id", func(c *Context) {
+		c.StringOk(c.PathParams["tenant"] + ":" + c.PathParams["id"])
+	})
+	req := httptest.NewRequest(http.MethodGet, "/user/42", nil)
+	req.Host = "acme.example.com"
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, "acme:42", w.Body.String())
+}
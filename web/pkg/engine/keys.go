@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+)
+
+// Value 返回 Context.Keys 里 key 对应的 T 类型值，不存在或者类型不匹配时返回 T 的零值和 false
+func Value[T any](c *Context, key string) (T, bool) {
+	var zero T
+	v, exists := c.Get(key)
+	if !exists {
+		return zero, false
+	}
+	value, ok := v.(T)
+	if !ok {
+		return zero, false
+	}
+	return value, true
+}
+
+// MustValue 返回 Context.Keys 里 key 对应的 T 类型值，不存在或者类型不匹配时 panic
+// 用于前置中间件一定会写入的值，比如认证中间件写入的当前用户
+func MustValue[T any](c *Context, key string) T {
+	v := c.MustGet(key)
+	value, ok := v.(T)
+	if !ok {
+		var zero T
+		panic(fmt.Sprintf("engine: key %q has type %T, not %T", key, v, zero))
+	}
+	return value
+}
+
+// MustGet 返回 key 对应的值，不存在时 panic
+func (c *Context) MustGet(key string) any {
+	if value, exists := c.Get(key); exists {
+		return value
+	}
+	panic(fmt.Sprintf("engine: key %q does not exist", key))
+}
+
+// GetBool returns the value associated with the key as a boolean.
+func (c *Context) GetBool(key string) (b bool) {
+	b, _ = Value[bool](c, key)
+	return
+}
+
+// GetInt64 returns the value associated with the key as an integer.
+func (c *Context) GetInt64(key string) (i int64) {
+	i, _ = Value[int64](c, key)
+	return
+}
+
+// GetFloat64 returns the value associated with the key as a float64.
+func (c *Context) GetFloat64(key string) (f float64) {
+	f, _ = Value[float64](c, key)
+	return
+}
+
+// GetDuration returns the value associated with the key as a duration.
+func (c *Context) GetDuration(key string) (d time.Duration) {
+	d, _ = Value[time.Duration](c, key)
+	return
+}
+
+// GetTime returns the value associated with the key as time.
+func (c *Context) GetTime(key string) (t time.Time) {
+	t, _ = Value[time.Time](c, key)
+	return
+}
+
+// GetStringSlice returns the value associated with the key as a slice of strings.
+func (c *Context) GetStringSlice(key string) (ss []string) {
+	ss, _ = Value[[]string](c, key)
+	return
+}
+
+// Key 类型化的 Context key，不同 Key 即使名字相同也互不影响，取值不需要类型断言
+// 比如 var UserKey = engine.NewKey[*User]("user")，中间件 UserKey.Set(c, user)，处理函数 UserKey.Get(c)
+type Key[T any] struct {
+	// 指针保证每个 Key 唯一
+	id *keyID
+}
+
+type keyID struct {
+	name string
+}
+
+// NewKey 创建类型化 key，name 只用于错误信息
+func NewKey[T any](name string) Key[T] {
+	return Key[T]{id: &keyID{name: name}}
+}
+
+// Name 返回 key 的名字
+func (k Key[T]) Name() string {
+	return k.id.name
+}
+
+// Set 写入值
+func (k Key[T]) Set(c *Context, value T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.typedKeys == nil {
+		c.typedKeys = make(map[*keyID]any)
+	}
+	c.typedKeys[k.id] = value
+}
+
+// Get 读取值，没有写入时返回 T 的零值和 false
+func (k Key[T]) Get(c *Context) (T, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	value, ok := c.typedKeys[k.id].(T)
+	return value, ok
+}
+
+// MustGet 读取值，没有写入时 panic
+func (k Key[T]) MustGet(c *Context) T {
+	value, ok := k.Get(c)
+	if !ok {
+		panic(fmt.Sprintf("engine: key %q does not exist", k.id.name))
+	}
+	return value
+}
@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type keysTestUser struct {
+	Name string
+}
+
+func TestValue(t *testing.T) {
+	c := NewContext(nil, nil)
+	c.Set("user", &keysTestUser{Name: "jun"})
+	c.Set("count", 3)
+
+	user, ok := Value[*keysTestUser](c, "user")
+	assert.True(t, ok)
+	assert.Equal(t, "jun", user.Name)
+	_, ok = Value[string](c, "count")
+	assert.False(t, ok)
+	_, ok = Value[int](c, "missing")
+	assert.False(t, ok)
+
+	assert.Equal(t, 3, MustValue[int](c, "count"))
+	assert.PanicsWithValue(t, `engine: key "missing" does not exist`, func() {
+		MustValue[int](c, "missing")
+	})
+	assert.PanicsWithValue(t, `engine: key "count" has type int, not string`, func() {
+		MustValue[string](c, "count")
+	})
+	assert.PanicsWithValue(t, `engine: key "missing" does not exist`, func() {
+		c.MustGet("missing")
+	})
+}
+
+func TestContext_TypedGetters(t *testing.T) {
+	now := time.Now()
+	c := NewContext(nil, nil)
+	c.Set("bool", true)
+	c.Set("int64", int64(64))
+	c.Set("float64", 1.5)
+	c.Set("duration", time.Second)
+	c.Set("time", now)
+	c.Set("strings", []string{"a"})
+
+	assert.True(t, c.GetBool("bool"))
+	assert.Equal(t, int64(64), c.GetInt64("int64"))
+	assert.Equal(t, 1.5, c.GetFloat64("float64"))
+	assert.Equal(t, time.Second, c.GetDuration("duration"))
+	assert.Equal(t, now, c.GetTime("time"))
+	assert.Equal(t, []string{"a"}, c.GetStringSlice("strings"))
+	// 类型不匹配返回零值
+	assert.False(t, c.GetBool("int64"))
+	assert.Equal(t, time.Duration(0), c.GetDuration("int64"))
+}
+
+func TestKey(t *testing.T) {
+	userKey := NewKey[*keysTestUser]("user")
+	otherKey := NewKey[*keysTestUser]("user")
+	c := NewContext(nil, nil)
+
+	_, ok := userKey.Get(c)
+	assert.False(t, ok)
+	assert.PanicsWithValue(t, `engine: key "user" does not exist`, func() {
+		userKey.MustGet(c)
+	})
+
+	userKey.Set(c, &keysTestUser{Name: "jun"})
+	user, ok := userKey.Get(c)
+	assert.True(t, ok)
+	assert.Equal(t, "jun", user.Name)
+	assert.Equal(t, "jun", userKey.MustGet(c).Name)
+	assert.Equal(t, "user", userKey.Name())
+
+	// 同名 key 和字符串 key 互不影响
+	_, ok = otherKey.Get(c)
+	assert.False(t, ok)
+	_, ok = c.Get("user")
+	assert.False(t, ok)
+}
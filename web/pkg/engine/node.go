@@ -25,6 +25,8 @@ type node struct {
 	children []*node
 	// 节点匹配到处理函数
 	handler HandlerFunc
+	// 节点对应注册路由
+	route *Route
 
 	// 节点匹配函数
 	nodeMatchFunc nodeMatchFunc
@@ -33,34 +35,134 @@ type node struct {
 	nodePathPattern string
 	// 节点类型
 	nodeType int
+	// 参数节点类型约束，比如 :id<int> 的 int，没有约束为空
+	paramType string
 
 	// 表示这个节点是路由路径一个node, 这个为true有handlerFunc, 为false 没有handlerFunc
 	end bool
 }
 
-func (n *node) findChild(path string, c *Context) (*node, bool) {
+// matchingChildren 返回匹配 path 的子节点，按优先级从高到低排序
+// 静态节点优先，然后是带类型约束的参数节点、普通参数节点，最后是 * 节点
+func (n *node) matchingChildren(path string) []*node {
 	foundNodes := make([]*node, 0, 2)
-
 	for _, child := range n.children {
-		if child.nodeMatchFunc(path, c) {
+		if child.nodeMatchFunc(path, nil) {
 			foundNodes = append(foundNodes, child)
 		}
 	}
+	sort.SliceStable(foundNodes, func(i int, j int) bool {
+		return foundNodes[i].priority() > foundNodes[j].priority()
+	})
+	return foundNodes
+}
+
+// match 查找 paths 对应的路由节点，一个子节点后面没有匹配的路由时回溯尝试下一个子节点
+// 比如注册了 /user/:id<int>/profile 和 /user/:name/settings，/user/42/settings 命中后者
+func (n *node) match(paths []string) (*node, bool) {
+	if len(paths) == 0 {
+		return n, n.end
+	}
+	for _, child := range n.matchingChildren(paths[0]) {
+		if found, ok := child.match(paths[1:]); ok {
+			return found, true
+		}
+	}
+	return nil, false
+}
 
-	if len(foundNodes) == 0 {
-		return nil, false
+// priority 匹配优先级，按节点类型，同是参数节点时带类型约束的优先
+func (n *node) priority() int {
+	p := n.nodeType * 2
+	if n.paramType != "" {
+		p++
 	}
+	return p
+}
 
-	// 在找到多个匹配子节点时，根据子节点类型排序，
-	sort.Slice(foundNodes, func(i int, j int) bool {
-		return foundNodes[i].nodeType < foundNodes[j].nodeType
-	})
+// childForPattern 注册路由时查找可以复用的子节点
+// 静态节点按 pattern 相等复用，参数节点按类型约束相同复用，* 节点不复用
+func (n *node) childForPattern(pattern string) (*node, bool) {
+	for _, child := range n.children {
+		switch child.nodeType {
+		case nodeTypeStatic:
+			if child.nodePathPattern == pattern {
+				return child, true
+			}
+		case nodeTypeParam:
+			if strings.HasPrefix(pattern, ":") {
+				if _, paramType := ParseParam(pattern); paramType == child.paramType {
+					return child, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// copy 浅复制节点，子节点列表复制一份，子节点本身仍然共享
+func (n *node) copy() *node {
+	cp := *n
+	cp.children = append(make([]*node, 0, len(n.children)+1), n.children...)
+	return &cp
+}
+
+// replaceChild 把子节点 old 替换成 child
+func (n *node) replaceChild(old *node, child *node) {
+	for i, c := range n.children {
+		if c == old {
+			n.children[i] = child
+			return
+		}
+	}
+}
 
-	return foundNodes[len(foundNodes)-1], true
+// removeChild 删除子节点，生成新的子节点列表
+func (n *node) removeChild(child *node) {
+	for i, c := range n.children {
+		if c == child {
+			n.children = append(n.children[:i:i], n.children[i+1:]...)
+			return
+		}
+	}
+}
 
+// routePath 按注册时的 pattern 查找路由节点，trimmedPattern 是去掉首尾 / 的 pattern
+// 返回从 n 到路由节点路径上的所有节点，没有找到返回 nil
+func (n *node) routePath(paths []string, trimmedPattern string) []*node {
+	if len(paths) == 0 {
+		if !n.end || n.route == nil || strings.Trim(n.route.Pattern, "/") != trimmedPattern {
+			return nil
+		}
+		return []*node{n}
+	}
+	segment := paths[0]
+	for _, child := range n.children {
+		switch child.nodeType {
+		case nodeTypeStatic:
+			if child.nodePathPattern != segment {
+				continue
+			}
+		case nodeTypeParam:
+			if !strings.HasPrefix(segment, ":") {
+				continue
+			}
+			if _, paramType := ParseParam(segment); paramType != child.paramType {
+				continue
+			}
+		case nodeTypeAny:
+			if segment != "*" {
+				continue
+			}
+		}
+		if path := child.routePath(paths[1:], trimmedPattern); path != nil {
+			return append([]*node{n}, path...)
+		}
+	}
+	return nil
 }
 
-func (n *node) addChild(paths []string, handler HandlerFunc) *node {
+func (n *node) addChild(paths []string, route *Route) *node {
 	currNode := n
 	for _, path := range paths {
 		child := newNode(path)
@@ -69,7 +171,8 @@ func (n *node) addChild(paths []string, handler HandlerFunc) *node {
 	}
 
 	// 到这里, 设置节点handler 和 路由节点标志
-	currNode.handler = handler
+	currNode.handler = route.Handler
+	currNode.route = route
 	currNode.end = true
 	return currNode
 }
@@ -113,18 +216,27 @@ func newNodeAny() *node {
 }
 
 func newNodeParam(pattern string) *node {
-	paramName := pattern[1:]
+	paramName, paramType := ParseParam(pattern)
+	// 带类型的参数只匹配校验通过的值，不匹配时继续尝试其他子节点
+	validator := func(path string) bool { return true }
+	if paramType != "" {
+		validator, _ = lookupParamType(paramType)
+	}
 	return &node{
 		children:        make([]*node, 0, 1),
 		nodeType:        nodeTypeParam,
 		end:             false,
 		nodePathPattern: pattern,
+		paramType:       paramType,
 		nodeMatchFunc: func(path string, c *Context) bool {
 			//fmt.Printf("pattern=%s, path=%s, paramName=%s\n ", pattern, path, paramName)
+			if path == "*" || !validator(path) {
+				return false
+			}
 			if c != nil {
 				c.PathParams[paramName] = path
 			}
-			return path != "*"
+			return true
 		},
 	}
 }
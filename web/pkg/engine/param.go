@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var ErrorUnknownParamType = errors.New("unknown path param type")
+var ErrorParamNotFound = errors.New("path param not found")
+var ErrorInvalidUUID = errors.New("invalid uuid")
+
+// ParamValidator 校验路径参数，返回 false 时参数节点不匹配，继续尝试其他路由
+type ParamValidator func(value string) bool
+
+var paramTypesMu sync.RWMutex
+
+// paramTypes 路由 pattern 里 :name<type> 可以使用的类型
+var paramTypes = map[string]ParamValidator{
+	"int": func(value string) bool {
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err == nil
+	},
+	"uint": func(value string) bool {
+		_, err := strconv.ParseUint(value, 10, 64)
+		return err == nil
+	},
+	"uuid": func(value string) bool {
+		_, err := ParseUUID(value)
+		return err == nil
+	},
+	"alpha": func(value string) bool {
+		return value != "" && strings.IndexFunc(value, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+		}) == -1
+	},
+	"alnum": func(value string) bool {
+		return value != "" && strings.IndexFunc(value, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+		}) == -1
+	},
+}
+
+// RegisterParamType 注册路径参数类型，比如 RegisterParamType("slug", isSlug) 之后可以使用 :name<slug>
+// 需要在注册路由之前调用，同名类型会被覆盖
+func RegisterParamType(name string, validator ParamValidator) {
+	paramTypesMu.Lock()
+	defer paramTypesMu.Unlock()
+	paramTypes[name] = validator
+}
+
+func lookupParamType(name string) (ParamValidator, bool) {
+	paramTypesMu.RLock()
+	defer paramTypesMu.RUnlock()
+	validator, ok := paramTypes[name]
+	return validator, ok
+}
+
+// ParseParam 解析参数段，比如 :id<int> 返回 id 和 int，没有类型时 paramType 为空
+func ParseParam(segment string) (name string, paramType string) {
+	name = strings.TrimPrefix(segment, ":")
+	if i := strings.IndexByte(name, '<'); i >= 0 && strings.HasSuffix(name, ">") {
+		return name[:i], name[i+1 : len(name)-1]
+	}
+	return name, ""
+}
+
+// validParamSegment 校验参数段格式和类型
+func validParamSegment(segment string) error {
+	name, paramType := ParseParam(segment)
+	if name == "" || strings.ContainsAny(name, "<>") {
+		return ErrorInvalidRouterPathPattern
+	}
+	if paramType == "" {
+		if strings.ContainsAny(segment, "<>") {
+			return ErrorInvalidRouterPathPattern
+		}
+		return nil
+	}
+	if _, ok := lookupParamType(paramType); !ok {
+		return ErrorUnknownParamType
+	}
+	return nil
+}
+
+// UUID 16 字节 UUID
+type UUID [16]byte
+
+// ParseUUID 解析 8-4-4-4-12 格式的 UUID，大小写都可以
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, ErrorInvalidUUID
+	}
+	compact := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	if _, err := hex.Decode(u[:], []byte(compact)); err != nil {
+		return UUID{}, ErrorInvalidUUID
+	}
+	return u, nil
+}
+
+// String 返回小写的 8-4-4-4-12 格式
+func (u UUID) String() string {
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf)
+}
+
+// Param 返回路径参数，不存在时返回空字符串
+func (c *Context) Param(key string) string {
+	return c.PathParams[key]
+}
+
+// ParamInt 返回 int 类型的路径参数，配合 :key<int> 使用时不会解析失败
+func (c *Context) ParamInt(key string) (int, error) {
+	value, ok := c.PathParams[key]
+	if !ok {
+		return 0, ErrorParamNotFound
+	}
+	return strconv.Atoi(value)
+}
+
+// ParamUint 返回 uint64 类型的路径参数，配合 :key<uint> 使用时不会解析失败
+func (c *Context) ParamUint(key string) (uint64, error) {
+	value, ok := c.PathParams[key]
+	if !ok {
+		return 0, ErrorParamNotFound
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// ParamUUID 返回 UUID 类型的路径参数，配合 :key<uuid> 使用时不会解析失败
+func (c *Context) ParamUUID(key string) (UUID, error) {
+	value, ok := c.PathParams[key]
+	if !ok {
+		return UUID{}, ErrorParamNotFound
+	}
+	return ParseUUID(value)
+}
@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypedParams(t *testing.T) {
+	RegisterParamType("slug", func(value string) bool {
+		return value != "" && strings.Trim(value, "abcdefghijklmnopqrstuvwxyz0123456789-") == ""
+	})
+	e := New()
+	handler := func(c *Context) {
+		c.StringOk(c.FullPath())
+	}
+	assert.Nil(t, e.AddRoute(http.MethodGet, "/user/:id<int>", handler))
+	assert.Nil(t, e.AddRoute(http.MethodGet, "/user/:id<int>/profile", handler))
+	assert.Nil(t, e.AddRoute(http.MethodGet, "/user/:name<alpha>", handler))
+	assert.Nil(t, e.AddRoute(http.MethodGet, "/user/me", handler))
+	assert.Nil(t, e.AddRoute(http.MethodGet, "/user/:other", handler))
+	assert.Nil(t, e.AddRoute(http.MethodGet, "/order/:id<uuid>", handler))
+	assert.Nil(t, e.AddRoute(http.MethodGet, "/count/:n<uint>", handler))
+	assert.Nil(t, e.AddRoute(http.MethodGet, "/code/:code<alnum>", handler))
+	assert.Nil(t, e.AddRoute(http.MethodGet, "/post/:slug<slug>", handler))
+	assert.Equal(t, ErrorUnknownParamType, e.AddRoute(http.MethodGet, "/bad/:id<float>", handler))
+	assert.Equal(t, ErrorInvalidRouterPathPattern, e.AddRoute(http.MethodGet, "/bad/:id<int", handler))
+	assert.Equal(t, ErrorInvalidRouterPathPattern, e.AddRoute(http.MethodGet, "/bad/:<int>", handler))
+
+	testCases := []struct {
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{path: "/user/42", wantCode: http.StatusOK, wantBody: "/user/:id<int>"},
+		{path: "/user/-42", wantCode: http.StatusOK, wantBody: "/user/:id<int>"},
+		{path: "/user/42/profile", wantCode: http.StatusOK, wantBody: "/user/:id<int>/profile"},
+		{path: "/user/bob", wantCode: http.StatusOK, wantBody: "/user/:name<alpha>"},
+		{path: "/user/me", wantCode: http.StatusOK, wantBody: "/user/me"},
+		{path: "/user/bob42", wantCode: http.StatusOK, wantBody: "/user/:other"},
+		{path: "/user/bob/profile", wantCode: http.StatusNotFound},
+		{path: "/order/6F9619FF-8B86-D011-B42D-00C04FC964FF", wantCode: http.StatusOK, wantBody: "/order/:id<uuid>"},
+		{path: "/order/6f9619ff8b86d011b42d00c04fc964ff", wantCode: http.StatusNotFound},
+		{path: "/count/7", wantCode: http.StatusOK, wantBody: "/count/:n<uint>"},
+		{path: "/count/-7", wantCode: http.StatusNotFound},
+		{path: "/code/abc123", wantCode: http.StatusOK, wantBody: "/code/:code<alnum>"},
+		{path: "/code/abc_123", wantCode: http.StatusNotFound},
+		{path: "/post/hello-world", wantCode: http.StatusOK, wantBody: "/post/:slug<slug>"},
+		{path: "/post/Hello", wantCode: http.StatusNotFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			assert.Equal(t, tc.wantCode, w.Code)
+			if tc.wantBody != "" {
+				assert.Equal(t, tc.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestTypedParams_Backtrack(t *testing.T) {
+	e := New()
+	handler := func(c *Context) {
+		c.StringOk(c.FullPath() + " " + c.PathParams["id"] + c.PathParams["name"])
+	}
+	assert.Nil(t, e.AddRoute(http.MethodGet, "/user/:id<int>/profile", handler))
+	assert.Nil(t, e.AddRoute(http.MethodGet, "/user/:name/settings", handler))
+	assert.Nil(t, e.AddRoute(http.MethodGet, "/user/*", handler))
+
+	testCases := []struct {
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{path: "/user/42/profile", wantCode: http.StatusOK, wantBody: "/user/:id<int>/profile 42"},
+		// 带类型约束的节点后面没有 settings，回溯到普通参数节点
+		{path: "/user/42/settings", wantCode: http.StatusOK, wantBody: "/user/:name/settings 42"},
+		{path: "/user/bob/settings", wantCode: http.StatusOK, wantBody: "/user/:name/settings bob"},
+		// 参数节点都走不通时回溯到 * 节点
+		{path: "/user/42", wantCode: http.StatusOK, wantBody: "/user/* "},
+		{path: "/user/bob/profile", wantCode: http.StatusNotFound},
+		{path: "/user/42/unknown", wantCode: http.StatusNotFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			assert.Equal(t, tc.wantCode, w.Code)
+			if tc.wantBody != "" {
+				assert.Equal(t, tc.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestContext_ParamAccessors(t *testing.T) {
+	e := New()
+	e.GET("/user/:id<int>/order/:orderId<uuid>/:n<uint>", func(c *Context) {
+		id, err := c.ParamInt("id")
+		assert.Nil(t, err)
+		assert.Equal(t, 42, id)
+		orderId, err := c.ParamUUID("orderId")
+		assert.Nil(t, err)
+		assert.Equal(t, "6f9619ff-8b86-d011-b42d-00c04fc964ff", orderId.String())
+		n, err := c.ParamUint("n")
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(18446744073709551615), n)
+		assert.Equal(t, "42", c.Param("id"))
+		_, err = c.ParamInt("missing")
+		assert.Equal(t, ErrorParamNotFound, err)
+		c.StringOk("ok")
+	}, WithName("order"))
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/42/order/6F9619FF-8B86-D011-B42D-00C04FC964FF/18446744073709551615", nil))
+	assert.Equal(t, "ok", w.Body.String())
+
+	url, err := e.URL("order", "id", "42", "orderId", "6f9619ff-8b86-d011-b42d-00c04fc964ff", "n", "1")
+	assert.Nil(t, err)
+	assert.Equal(t, "/user/42/order/6f9619ff-8b86-d011-b42d-00c04fc964ff/1", url)
+}
+
+func TestParseUUID(t *testing.T) {
+	u, err := ParseUUID("6F9619FF-8B86-D011-B42D-00C04FC964FF")
+	assert.Nil(t, err)
+	assert.Equal(t, "6f9619ff-8b86-d011-b42d-00c04fc964ff", u.String())
+	for _, invalid := range []string{"", "6f9619ff-8b86-d011-b42d-00c04fc964f", "6f9619ff_8b86-d011-b42d-00c04fc964ff", "6f9619ff-8b86-d011-b42d-00c04fc964fg"} {
+		_, err = ParseUUID(invalid)
+		assert.Equal(t, ErrorInvalidUUID, err, invalid)
+	}
+}
+
+func TestTreeBasedRouter_AddRouteReuseNodes(t *testing.T) {
+	router := NewTreeBasedRouter().(*TreeBasedRouter)
+	handler := func(c *Context) {}
+	_ = router.AddRoute(http.MethodGet, "/blog/:id", handler)
+	// 静态段不能复用参数节点
+	_ = router.AddRoute(http.MethodGet, "/blog/detail", handler)
+	_ = router.AddRoute(http.MethodGet, "/blog/:id/comments", handler)
+	_ = router.AddRoute(http.MethodGet, "/blog/:id<int>", handler)
+	_ = router.AddRoute(http.MethodGet, "/blog/:postId<int>/likes", handler)
+	blogNode := router.routeForest[http.MethodGet].children[0]
+	assert.Equal(t, 3, len(blogNode.children))
+
+	context := NewContext(nil, nil)
+	n, ok := router.findRoute(http.MethodGet, "/blog/abc", context)
+	assert.True(t, ok)
+	assert.Equal(t, "/blog/:id", n.route.Pattern)
+	n, ok = router.findRoute(http.MethodGet, "/blog/detail", context)
+	assert.True(t, ok)
+	assert.Equal(t, "/blog/detail", n.route.Pattern)
+	context = NewContext(nil, nil)
+	n, ok = router.findRoute(http.MethodGet, "/blog/1/likes", context)
+	assert.True(t, ok)
+	assert.Equal(t, "/blog/:postId<int>/likes", n.route.Pattern)
+	// 共用参数节点时参数名以命中路由为准
+	assert.Equal(t, map[string]string{"postId": "1"}, context.PathParams)
+}
@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// queryValues 解析一次查询参数并缓存
+func (c *Context) queryValues() url.Values {
+	if c.queryCache == nil {
+		c.queryCache = c.R.URL.Query()
+	}
+	return c.queryCache
+}
+
+// formValues 返回请求体表单参数，multipart 表单按 Engine.Upload 配置解析，解析失败返回空
+func (c *Context) formValues() url.Values {
+	if c.R.PostForm == nil {
+		if strings.HasPrefix(c.GetHeader("Content-Type"), "multipart/form-data") {
+			_, _ = c.MultipartForm()
+		} else {
+			_ = c.R.ParseForm()
+		}
+	}
+	return c.R.PostForm
+}
+
+// GetQuery 返回查询参数和是否存在
+func (c *Context) GetQuery(key string) (string, bool) {
+	values, ok := c.queryValues()[key]
+	if !ok || len(values) == 0 {
+		return "", false
+	}
+	return values[0], true
+}
+
+// DefaultQuery 返回查询参数，不存在时返回 defaultValue
+func (c *Context) DefaultQuery(key string, defaultValue string) string {
+	if value, ok := c.GetQuery(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// QueryInt 返回 int 类型查询参数，不存在或者解析失败时返回 defaultValue
+func (c *Context) QueryInt(key string, defaultValue int) int {
+	value, ok := c.GetQuery(key)
+	return parseInt(value, ok, defaultValue)
+}
+
+// QueryInt64 返回 int64 类型查询参数，不存在或者解析失败时返回 defaultValue
+func (c *Context) QueryInt64(key string, defaultValue int64) int64 {
+	value, ok := c.GetQuery(key)
+	return parseInt64(value, ok, defaultValue)
+}
+
+// QueryBool 返回 bool 类型查询参数，接受 1/0/true/false 等 strconv.ParseBool 支持的值
+// 只有参数名没有值时，比如 ?debug，返回 true
+func (c *Context) QueryBool(key string, defaultValue bool) bool {
+	value, ok := c.GetQuery(key)
+	return parseBool(value, ok, defaultValue)
+}
+
+// QueryDuration 返回 time.Duration 类型查询参数，格式和 time.ParseDuration 一样，比如 1m30s
+func (c *Context) QueryDuration(key string, defaultValue time.Duration) time.Duration {
+	value, ok := c.GetQuery(key)
+	if !ok {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return defaultValue
+	}
+	return d
+}
+
+// QueryArray 返回同名查询参数的所有值，比如 ?id=1&id=2
+func (c *Context) QueryArray(key string) []string {
+	return c.queryValues()[key]
+}
+
+// QueryMap 返回 key[name]=value 形式的查询参数，比如 ?user[name]=jun&user[age]=20
+func (c *Context) QueryMap(key string) map[string]string {
+	return bracketMap(c.queryValues(), key)
+}
+
+// PostFormInt 返回 int 类型表单参数，不存在或者解析失败时返回 defaultValue
+func (c *Context) PostFormInt(key string, defaultValue int) int {
+	value, ok := firstValue(c.formValues(), key)
+	return parseInt(value, ok, defaultValue)
+}
+
+// PostFormBool 返回 bool 类型表单参数，不存在或者解析失败时返回 defaultValue
+func (c *Context) PostFormBool(key string, defaultValue bool) bool {
+	value, ok := firstValue(c.formValues(), key)
+	return parseBool(value, ok, defaultValue)
+}
+
+// PostFormArray 返回同名表单参数的所有值
+func (c *Context) PostFormArray(key string) []string {
+	return c.formValues()[key]
+}
+
+// PostFormMap 返回 key[name]=value 形式的表单参数
+func (c *Context) PostFormMap(key string) map[string]string {
+	return bracketMap(c.formValues(), key)
+}
+
+func firstValue(values url.Values, key string) (string, bool) {
+	v, ok := values[key]
+	if !ok || len(v) == 0 {
+		return "", false
+	}
+	return v[0], true
+}
+
+func parseInt(value string, ok bool, defaultValue int) int {
+	if !ok {
+		return defaultValue
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultValue
+	}
+	return i
+}
+
+func parseInt64(value string, ok bool, defaultValue int64) int64 {
+	if !ok {
+		return defaultValue
+	}
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return i
+}
+
+func parseBool(value string, ok bool, defaultValue bool) bool {
+	if !ok {
+		return defaultValue
+	}
+	if value == "" {
+		return true
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return defaultValue
+	}
+	return b
+}
+
+// bracketMap 收集 key[name]=value 形式的参数，同名取第一个值
+func bracketMap(values url.Values, key string) map[string]string {
+	result := make(map[string]string)
+	prefix := key + "["
+	for k, v := range values {
+		if len(v) == 0 || !strings.HasPrefix(k, prefix) || !strings.HasSuffix(k, "]") {
+			continue
+		}
+		name := k[len(prefix) : len(k)-1]
+		if name == "" || strings.ContainsAny(name, "[]") {
+			continue
+		}
+		result[name] = v[0]
+	}
+	return result
+}
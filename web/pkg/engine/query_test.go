@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContext_QueryAccessors(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/?page=2&size=x&debug&verbose=false&id=1&id=2&user[name]=jun&user[age]=20&user[]=x&user[a][b]=y&timeout=1m30s&big=9007199254740993", nil)
+	c := NewContext(httptest.NewRecorder(), req)
+
+	assert.Equal(t, 2, c.QueryInt("page", 1))
+	assert.Equal(t, 10, c.QueryInt("size", 10))
+	assert.Equal(t, 1, c.QueryInt("missing", 1))
+	assert.Equal(t, int64(9007199254740993), c.QueryInt64("big", 0))
+	assert.True(t, c.QueryBool("debug", false))
+	assert.False(t, c.QueryBool("verbose", true))
+	assert.True(t, c.QueryBool("missing", true))
+	assert.Equal(t, 90*time.Second, c.QueryDuration("timeout", time.Second))
+	assert.Equal(t, time.Second, c.QueryDuration("page", time.Second))
+	assert.Equal(t, []string{"1", "2"}, c.QueryArray("id"))
+	assert.Nil(t, c.QueryArray("missing"))
+	assert.Equal(t, map[string]string{"name": "jun", "age": "20"}, c.QueryMap("user"))
+	assert.Empty(t, c.QueryMap("missing"))
+	assert.Equal(t, "1", c.Query("id"))
+	assert.Equal(t, "fallback", c.DefaultQuery("missing", "fallback"))
+	value, ok := c.GetQuery("debug")
+	assert.True(t, ok)
+	assert.Equal(t, "", value)
+}
+
+func TestContext_PostFormAccessors(t *testing.T) {
+	body := "age=20&agree=true&tag=a&tag=b&addr[city]=hz&addr[zip]=310000"
+	req := httptest.NewRequest(http.MethodPost, "/?age=99", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c := NewContext(httptest.NewRecorder(), req)
+
+	assert.Equal(t, 20, c.PostFormInt("age", 0))
+	assert.True(t, c.PostFormBool("agree", false))
+	assert.Equal(t, []string{"a", "b"}, c.PostFormArray("tag"))
+	assert.Equal(t, map[string]string{"city": "hz", "zip": "310000"}, c.PostFormMap("addr"))
+	assert.Equal(t, 99, c.QueryInt("age", 0))
+
+	buf := &bytes.Buffer{}
+	writer := multipart.NewWriter(buf)
+	_ = writer.WriteField("age", "30")
+	_ = writer.WriteField("tag", "x")
+	_ = writer.Close()
+	e := New()
+	e.POST("/form", func(c *Context) {
+		assert.Equal(t, 30, c.PostFormInt("age", 0))
+		assert.Equal(t, []string{"x"}, c.PostFormArray("tag"))
+		c.StringOk("ok")
+	})
+	req = httptest.NewRequest(http.MethodPost, "/form", buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, "ok", w.Body.String())
+}
@@ -0,0 +1,146 @@
+package engine
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// TrailingSlashMode 请求路径和注册路由的末尾 / 不一致时的处理方式
+type TrailingSlashMode int
+
+const (
+	// TrailingSlashIgnore 忽略末尾 /，/blog/ 命中 /blog，默认方式
+	TrailingSlashIgnore TrailingSlashMode = iota
+	// TrailingSlashStrict 末尾 / 必须和注册路由一致，否则返回 404
+	TrailingSlashStrict
+	// TrailingSlashRedirect 重定向到注册路由的形式，GET 和 HEAD 返回 301，其他方法返回 308
+	TrailingSlashRedirect
+)
+
+// RouterOptions 路由匹配配置，只对前缀树路由器生效
+type RouterOptions struct {
+	// TrailingSlash 末尾 / 处理方式，注册 /blog 和 /blog/ 是同一个路由，后注册的覆盖先注册的
+	TrailingSlash TrailingSlashMode
+	// CleanPath 请求路径包含 .、.. 或者重复的 / 时重定向到规范路径
+	CleanPath bool
+	// CaseInsensitive 没有命中路由时忽略大小写再查找一次，找到时重定向到注册路由的大小写
+	CaseInsensitive bool
+}
+
+// redirectCode GET 和 HEAD 使用 301，其他方法使用 308 保证重定向后方法和请求体不变
+func redirectCode(method string) int {
+	if method == http.MethodGet || method == http.MethodHead {
+		return http.StatusMovedPermanently
+	}
+	return http.StatusPermanentRedirect
+}
+
+// redirectTo 在处理链末尾追加重定向，全局中间件仍然执行
+// 开头多个 / 合并成一个，避免 //evil.com 变成协议相对地址跳到其他站点
+func redirectTo(c *Context, p string) {
+	p = "/" + strings.TrimLeft(p, "/")
+	location := (&url.URL{Path: p, RawQuery: c.R.URL.RawQuery}).String()
+	c.handlers = append(c.handlers, func(c *Context) {
+		c.Redirect(redirectCode(c.Method), location)
+	})
+	c.Next()
+}
+
+// cleanPath 返回规范路径，去掉 .、.. 和重复的 /，保留末尾的 /
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	cleaned := path.Clean(p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+func hasTrailingSlash(p string) bool {
+	return len(p) > 1 && strings.HasSuffix(p, "/")
+}
+
+// routerOptions 返回 Context 所属 Engine 的路由配置，单独使用路由器时返回默认配置
+func routerOptions(c *Context) RouterOptions {
+	if c.engine == nil {
+		return RouterOptions{}
+	}
+	return c.engine.RouterOptions
+}
+
+// checkTrailingSlash 命中路由后检查末尾 /，返回 false 表示已经处理了请求
+func checkTrailingSlash(c *Context, route *Route) bool {
+	mode := routerOptions(c).TrailingSlash
+	if mode == TrailingSlashIgnore || hasTrailingSlash(c.Path) == hasTrailingSlash(route.Pattern) {
+		return true
+	}
+	if mode == TrailingSlashStrict {
+		c.handlers = append(c.handlers, notFoundHandler)
+		c.Next()
+		return false
+	}
+	if hasTrailingSlash(c.Path) {
+		redirectTo(c, strings.TrimRight(c.Path, "/"))
+	} else {
+		redirectTo(c, c.Path+"/")
+	}
+	return false
+}
+
+// findCaseInsensitivePath 忽略大小写查找路由，返回注册路由大小写的路径
+func (t *TreeBasedRouter) findCaseInsensitivePath(method string, p string) (string, bool) {
+	rootNode, ok := t.routeForest[method]
+	if !ok {
+		return "", false
+	}
+	paths := strings.Split(strings.Trim(p, "/"), "/")
+	fixed, ok := rootNode.findCaseInsensitive(paths, make([]string, 0, len(paths)))
+	if !ok {
+		return "", false
+	}
+	result := "/" + strings.Join(fixed, "/")
+	if hasTrailingSlash(p) {
+		result += "/"
+	}
+	return result, true
+}
+
+// findCaseInsensitive 深度优先查找，静态节点忽略大小写比较，参数和通配节点保留请求里的原值
+func (n *node) findCaseInsensitive(paths []string, fixed []string) ([]string, bool) {
+	if len(paths) == 0 {
+		return fixed, n.end
+	}
+	segment := paths[0]
+	// 和 match 一样，静态节点优先
+	for _, nodeType := range []int{nodeTypeStatic, nodeTypeParam, nodeTypeAny} {
+		for _, child := range n.children {
+			if child.nodeType != nodeType {
+				continue
+			}
+			var value string
+			switch nodeType {
+			case nodeTypeStatic:
+				if !strings.EqualFold(child.nodePathPattern, segment) {
+					continue
+				}
+				value = child.nodePathPattern
+			default:
+				if !child.nodeMatchFunc(segment, nil) {
+					continue
+				}
+				value = segment
+			}
+			if result, ok := child.findCaseInsensitive(paths[1:], append(fixed, value)); ok {
+				return result, true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newRedirectTestEngine(options RouterOptions) *Engine {
+	e := New()
+	e.RouterOptions = options
+	handler := func(c *Context) {
+		c.StringOk(c.FullPath())
+	}
+	e.GET("/blog", handler)
+	e.POST("/blog", handler)
+	e.GET("/docs/", handler)
+	e.GET("/User/:id/Profile", handler)
+	e.GET("/user/:id/settings", handler)
+	return e
+}
+
+func TestRouterOptions_TrailingSlash(t *testing.T) {
+	testCases := []struct {
+		name         string
+		mode         TrailingSlashMode
+		method       string
+		path         string
+		wantCode     int
+		wantLocation string
+	}{
+		{name: "ignore", mode: TrailingSlashIgnore, method: http.MethodGet, path: "/blog/", wantCode: http.StatusOK},
+		{name: "ignore missing slash", mode: TrailingSlashIgnore, method: http.MethodGet, path: "/docs", wantCode: http.StatusOK},
+		{name: "strict", mode: TrailingSlashStrict, method: http.MethodGet, path: "/blog/", wantCode: http.StatusNotFound},
+		{name: "strict missing slash", mode: TrailingSlashStrict, method: http.MethodGet, path: "/docs", wantCode: http.StatusNotFound},
+		{name: "strict match", mode: TrailingSlashStrict, method: http.MethodGet, path: "/docs/", wantCode: http.StatusOK},
+		{name: "redirect get", mode: TrailingSlashRedirect, method: http.MethodGet, path: "/blog/?page=2", wantCode: http.StatusMovedPermanently, wantLocation: "/blog?page=2"},
+		{name: "redirect post", mode: TrailingSlashRedirect, method: http.MethodPost, path: "/blog/", wantCode: http.StatusPermanentRedirect, wantLocation: "/blog"},
+		{name: "redirect add slash", mode: TrailingSlashRedirect, method: http.MethodGet, path: "/docs", wantCode: http.StatusMovedPermanently, wantLocation: "/docs/"},
+		{name: "redirect match", mode: TrailingSlashRedirect, method: http.MethodGet, path: "/blog", wantCode: http.StatusOK},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := newRedirectTestEngine(RouterOptions{TrailingSlash: tc.mode})
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+			assert.Equal(t, tc.wantCode, w.Code)
+			assert.Equal(t, tc.wantLocation, w.Header().Get("Location"))
+		})
+	}
+
+	// 开头重复的 / 不能重定向到协议相对地址 //evil.com
+	e := New()
+	e.RouterOptions = RouterOptions{TrailingSlash: TrailingSlashRedirect}
+	e.GET("/:slug", func(c *Context) {})
+	for _, p := range []string{"//evil.com/", "///evil.com/"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.URL.Path = p
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusMovedPermanently, w.Code)
+		assert.Equal(t, "/evil.com", w.Header().Get("Location"), p)
+	}
+}
+
+func TestRouterOptions_CleanPath(t *testing.T) {
+	testCases := []struct {
+		path         string
+		wantCode     int
+		wantLocation string
+	}{
+		{path: "//blog", wantCode: http.StatusMovedPermanently, wantLocation: "/blog"},
+		{path: "/docs/../blog", wantCode: http.StatusMovedPermanently, wantLocation: "/blog"},
+		{path: "/./docs//", wantCode: http.StatusMovedPermanently, wantLocation: "/docs/"},
+		{path: "/blog", wantCode: http.StatusOK},
+		{path: "/", wantCode: http.StatusNotFound},
+	}
+	e := newRedirectTestEngine(RouterOptions{CleanPath: true})
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			// httptest.NewRequest 不会规范化路径，这里直接设置
+			req.URL.Path = tc.path
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+			assert.Equal(t, tc.wantCode, w.Code)
+			assert.Equal(t, tc.wantLocation, w.Header().Get("Location"))
+		})
+	}
+
+	// 默认不规范化，//blog 仍然命中 /blog
+	e = newRedirectTestEngine(RouterOptions{})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.URL.Path = "//blog"
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRouterOptions_CaseInsensitive(t *testing.T) {
+	testCases := []struct {
+		path         string
+		wantCode     int
+		wantLocation string
+	}{
+		{path: "/BLOG", wantCode: http.StatusMovedPermanently, wantLocation: "/blog"},
+		{path: "/user/AbC/profile", wantCode: http.StatusMovedPermanently, wantLocation: "/User/AbC/Profile"},
+		{path: "/USER/AbC/SETTINGS/", wantCode: http.StatusMovedPermanently, wantLocation: "/user/AbC/settings/"},
+		{path: "/User/AbC/Profile", wantCode: http.StatusOK},
+		{path: "/unknown", wantCode: http.StatusNotFound},
+	}
+	e := newRedirectTestEngine(RouterOptions{CaseInsensitive: true})
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			assert.Equal(t, tc.wantCode, w.Code)
+			assert.Equal(t, tc.wantLocation, w.Header().Get("Location"))
+		})
+	}
+
+	w := httptest.NewRecorder()
+	newRedirectTestEngine(RouterOptions{}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/BLOG", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCleanPath(t *testing.T) {
+	testCases := map[string]string{
+		"":             "/",
+		"blog":         "/blog",
+		"/a/b/../c":    "/a/c",
+		"/a/./b/":      "/a/b/",
+		"/../a":        "/a",
+		"//a///b//":    "/a/b/",
+		"/":            "/",
+		"/a/b/c/../..": "/a",
+	}
+	for input, want := range testCases {
+		assert.Equal(t, want, cleanPath(input), input)
+	}
+}
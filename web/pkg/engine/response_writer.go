@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+var ErrorHijackUnsupported = errors.New("response writer does not implement http.Hijacker")
+
+// responseWriter 包装 http.ResponseWriter，记录状态码和写入字节数，方便中间件在处理完成后读取
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+	// bodyTooLarge 读取请求体时超过了大小限制
+	bodyTooLarge bool
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status != 0 {
+		return
+	}
+	// 请求体超过限制后不管处理函数返回什么状态码都改为 413
+	if w.bodyTooLarge {
+		code = http.StatusRequestEntityTooLarge
+	}
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.size += n
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.WriteHeader(http.StatusOK)
+		}
+		flusher.Flush()
+	}
+}
+
+// Hijack 升级连接后状态码记为 101
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, ErrorHijackUnsupported
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return hijacker.Hijack()
+}
+
+// Unwrap 返回原始 ResponseWriter
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
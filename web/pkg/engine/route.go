@@ -0,0 +1,79 @@
+package engine
+
+import "time"
+
+// Route 一条注册的路由，保存路由处理函数以及路由级别的配置
+type Route struct {
+	Method  string
+	Pattern string
+	// 路由名字，通过 Engine.URL 生成路径，空表示没有名字
+	Name string
+	// 路由所属 Host 模式，空表示默认路由树
+	Host string
+	// 路由处理函数
+	Handler HandlerFunc
+	// 路由级别中间件，在 Engine 全局中间件之后，Handler 之前执行
+	Middlewares []HandlerFunc
+	// 请求体大小限制，0 表示沿用 Engine.MaxBodyBytes
+	MaxBodyBytes int64
+	// 文件上传配置，nil 表示沿用 Engine.Upload
+	Upload *UploadOptions
+	// 处理超时时间，0 表示不限制，参考 WithTimeout
+	Timeout time.Duration
+	// 路由元数据，比如需要的角色和权限，中间件通过 Context.RouteMeta 读取
+	Meta map[string]any
+}
+
+// RouteOption 注册路由时的可选配置
+type RouteOption func(route *Route)
+
+// WithMiddlewares 设置路由级别中间件
+func WithMiddlewares(middlewares ...HandlerFunc) RouteOption {
+	return func(route *Route) {
+		route.Middlewares = append(route.Middlewares, middlewares...)
+	}
+}
+
+// WithMaxBodyBytes 设置路由请求体大小限制，覆盖 Engine.MaxBodyBytes，小于 0 表示该路由不限制
+func WithMaxBodyBytes(n int64) RouteOption {
+	return func(route *Route) {
+		route.MaxBodyBytes = n
+	}
+}
+
+// WithUploadOptions 设置路由文件上传配置，覆盖 Engine.Upload
+func WithUploadOptions(options UploadOptions) RouteOption {
+	return func(route *Route) {
+		route.Upload = &options
+	}
+}
+
+// WithTimeout 设置路由处理超时时间，超时后请求的 context 被取消
+// 处理函数需要监听 c.R.Context().Done() 或者 c.Done() 及时返回，超时且没有写响应时返回 503
+func WithTimeout(timeout time.Duration) RouteOption {
+	return func(route *Route) {
+		route.Timeout = timeout
+	}
+}
+
+// WithMeta 设置路由元数据
+func WithMeta(key string, value any) RouteOption {
+	return func(route *Route) {
+		if route.Meta == nil {
+			route.Meta = make(map[string]any)
+		}
+		route.Meta[key] = value
+	}
+}
+
+func newRoute(method string, pattern string, handler HandlerFunc, opts ...RouteOption) *Route {
+	route := &Route{
+		Method:  method,
+		Pattern: pattern,
+		Handler: handler,
+	}
+	for _, opt := range opts {
+		opt(route)
+	}
+	return route
+}
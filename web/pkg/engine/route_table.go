@@ -0,0 +1,303 @@
+package engine
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+var ErrorRouteNotFound = errors.New("route not found")
+
+// routeTable Engine 的路由快照，发布之后不再修改
+// 添加和删除路由时复制一份快照，修改副本后原子替换，处理请求时读取快照不需要加锁
+type routeTable struct {
+	// 默认路由树
+	router Router
+	// 按 Host 划分的路由树，按匹配优先级排序
+	hosts []*hostRouter
+	// 挂载的 http.Handler，按前缀长度倒序
+	mounts []*Route
+	// 命名路由，Engine.URL 根据名字查找
+	names map[string]*Route
+	// names 是否已经复制，clone 之后和原快照共享，第一次写入时复制
+	namesOwned bool
+	// 全局中间件，Engine.Use 添加
+	middlewares []HandlerFunc
+}
+
+// hostRouter 一个 Host 模式对应的路由树
+type hostRouter struct {
+	pattern string
+	// 按 . 分割的 host 模式，比如 [:tenant example com]
+	labels []string
+	// 静态 label 数量，越多越优先匹配
+	static int
+	router Router
+}
+
+func newRouteTable(router Router) *routeTable {
+	return &routeTable{
+		router:     router,
+		names:      make(map[string]*Route),
+		namesOwned: true,
+	}
+}
+
+// clone 浅复制快照，路由树和命名路由仍然共享，修改路由树前需要调用 mutableRouter
+// 中间件列表追加时使用完整切片表达式，不会写到原快照的底层数组
+func (t *routeTable) clone() *routeTable {
+	cp := &routeTable{
+		router:      t.router,
+		hosts:       make([]*hostRouter, 0, len(t.hosts)),
+		mounts:      append([]*Route(nil), t.mounts...),
+		names:       t.names,
+		middlewares: t.middlewares[:len(t.middlewares):len(t.middlewares)],
+	}
+	for _, h := range t.hosts {
+		hc := *h
+		cp.hosts = append(cp.hosts, &hc)
+	}
+	return cp
+}
+
+// mutableRouter 复制 host 对应的路由树并替换到当前副本，返回可以修改的路由树
+// host 为空表示默认路由树，create 为 true 时 host 路由树不存在则创建
+func (t *routeTable) mutableRouter(host *Host, create bool) (Router, bool) {
+	if host == nil {
+		t.router = t.router.Clone()
+		return t.router, true
+	}
+	for _, h := range t.hosts {
+		if h.pattern == host.pattern {
+			h.router = h.router.Clone()
+			return h.router, true
+		}
+	}
+	if !create {
+		return nil, false
+	}
+	h := &hostRouter{
+		pattern: host.pattern,
+		labels:  host.labels,
+		static:  host.static,
+		router:  NewTreeBasedRouter(),
+	}
+	t.hosts = append(t.hosts, h)
+	sort.SliceStable(t.hosts, func(i, j int) bool {
+		return t.hosts[i].static > t.hosts[j].static
+	})
+	return h.router, true
+}
+
+// allRoutes 返回快照里所有路由
+func (t *routeTable) allRoutes() []*Route {
+	routes := t.router.Routes()
+	for _, h := range t.hosts {
+		routes = append(routes, h.router.Routes()...)
+	}
+	return append(routes, t.mounts...)
+}
+
+// checkName 检查路由名字是否可用，名字重复并且 pattern 不同时返回 ErrorDuplicateRouteName
+// 添加路由前调用，名字冲突时路由不会加入路由树
+func (t *routeTable) checkName(route *Route) error {
+	if route == nil || route.Name == "" {
+		return nil
+	}
+	// Any 给多个方法注册同一个 pattern，名字可以相同
+	if existing, ok := t.names[route.Name]; ok && existing.Pattern != route.Pattern {
+		return ErrorDuplicateRouteName
+	}
+	return nil
+}
+
+// registerName 记录命名路由，名字重复并且 pattern 不同时返回 ErrorDuplicateRouteName
+func (t *routeTable) registerName(route *Route) error {
+	if err := t.checkName(route); err != nil {
+		return err
+	}
+	if route == nil || route.Name == "" {
+		return nil
+	}
+	if _, ok := t.names[route.Name]; ok {
+		return nil
+	}
+	if !t.namesOwned {
+		names := make(map[string]*Route, len(t.names)+1)
+		for name, r := range t.names {
+			names[name] = r
+		}
+		t.names = names
+		t.namesOwned = true
+	}
+	t.names[route.Name] = route
+	return nil
+}
+
+// rebuildNames 删除路由之后重新生成命名路由
+func (t *routeTable) rebuildNames() {
+	t.names = make(map[string]*Route, len(t.names))
+	t.namesOwned = true
+	for _, route := range t.allRoutes() {
+		_ = t.registerName(route)
+	}
+}
+
+// findMount 返回路径命中的挂载路由
+func (t *routeTable) findMount(path string) *Route {
+	for _, route := range t.mounts {
+		prefix := strings.TrimSuffix(route.Pattern, "/*")
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return route
+		}
+	}
+	return nil
+}
+
+// routerFor 根据请求 Host 选择路由树
+func (t *routeTable) routerFor(c *Context) Router {
+	if len(t.hosts) == 0 {
+		return t.router
+	}
+	labels := strings.Split(normalizeHost(stripPort(c.R.Host)), ".")
+	for _, h := range t.hosts {
+		if h.match(labels, c) {
+			return h.router
+		}
+	}
+	return t.router
+}
+
+// match 判断 host 是否匹配，匹配时把参数写入 c.PathParams
+func (h *hostRouter) match(labels []string, c *Context) bool {
+	if len(labels) != len(h.labels) {
+		return false
+	}
+	for i, label := range h.labels {
+		if label != "*" && label[0] != ':' && label != labels[i] {
+			return false
+		}
+	}
+	for i, label := range h.labels {
+		if label[0] == ':' {
+			c.PathParams[label[1:]] = labels[i]
+		}
+	}
+	return true
+}
+
+// updateRoutes 复制当前路由快照交给 update 修改，update 返回错误时放弃修改，否则原子替换快照
+// 修改之间加锁串行执行，处理请求不受影响
+func (e *Engine) updateRoutes(update func(t *routeTable) error) error {
+	e.routesMu.Lock()
+	defer e.routesMu.Unlock()
+	t := e.table.Load().clone()
+	if err := update(t); err != nil {
+		return err
+	}
+	e.table.Store(t)
+	return nil
+}
+
+// addRoute 在副本上添加路由，host 为 nil 表示默认路由树
+func (t *routeTable) addRoute(host *Host, method string, pattern string, handler HandlerFunc, opts ...RouteOption) error {
+	if host != nil {
+		if host.err != nil {
+			return host.err
+		}
+		opts = append(opts[:len(opts):len(opts)], func(route *Route) {
+			route.Host = host.pattern
+		})
+	}
+	// 先检查名字再加入路由树，名字冲突时不添加路由
+	if err := t.checkName(newRoute(method, pattern, handler, opts...)); err != nil {
+		return err
+	}
+	// 最后追加一个选项拿到路由器创建的路由，用于记录命名路由
+	var added *Route
+	opts = append(opts[:len(opts):len(opts)], func(route *Route) {
+		added = route
+	})
+	router, _ := t.mutableRouter(host, true)
+	if err := router.AddRoute(method, pattern, handler, opts...); err != nil {
+		return err
+	}
+	return t.registerName(added)
+}
+
+// removeRoute 在副本上删除路由，host 为 nil 表示默认路由树
+func (t *routeTable) removeRoute(host *Host, method string, pattern string) error {
+	if host != nil && host.err != nil {
+		return host.err
+	}
+	router, ok := t.mutableRouter(host, false)
+	if !ok {
+		return ErrorRouteNotFound
+	}
+	if !router.RemoveRoute(method, pattern) {
+		return ErrorRouteNotFound
+	}
+	t.rebuildNames()
+	return nil
+}
+
+func (e *Engine) addRoute(host *Host, method string, pattern string, handler HandlerFunc, opts ...RouteOption) error {
+	return e.updateRoutes(func(t *routeTable) error {
+		return t.addRoute(host, method, pattern, handler, opts...)
+	})
+}
+
+func (e *Engine) removeRoute(host *Host, method string, pattern string) error {
+	return e.updateRoutes(func(t *routeTable) error {
+		return t.removeRoute(host, method, pattern)
+	})
+}
+
+// RouteBatch UpdateRoutes 里的一组路由修改，全部成功后一起生效
+type RouteBatch struct {
+	table *routeTable
+}
+
+// UpdateRoutes 在同一份路由快照上执行 update 里的添加和删除，update 返回错误时全部放弃，否则一次原子替换
+// 正在处理的请求要么看到修改前的路由，要么看到全部修改；update 里不能再调用 Engine 的路由方法，比如 Engine.Host
+func (e *Engine) UpdateRoutes(update func(b *RouteBatch) error) error {
+	return e.updateRoutes(func(t *routeTable) error {
+		return update(&RouteBatch{table: t})
+	})
+}
+
+// AddRoute 添加路由，host 为 nil 表示默认路由树，否则是 Engine.Host 返回的 Host 路由树
+func (b *RouteBatch) AddRoute(host *Host, method string, pattern string, handler HandlerFunc, opts ...RouteOption) error {
+	return b.table.addRoute(host, method, pattern, handler, opts...)
+}
+
+// RemoveRoute 删除路由，路由不存在返回 ErrorRouteNotFound
+func (b *RouteBatch) RemoveRoute(host *Host, method string, pattern string) error {
+	return b.table.removeRoute(host, method, pattern)
+}
+
+// RemoveRoute 删除默认路由树上的路由，pattern 和注册时一致，路由不存在返回 ErrorRouteNotFound
+// 可以在处理请求的同时调用，正在处理的请求不受影响
+func (e *Engine) RemoveRoute(method string, pattern string) error {
+	return e.removeRoute(nil, method, pattern)
+}
+
+// RemoveRoute 删除 Host 路由树上的路由
+func (h *Host) RemoveRoute(method string, pattern string) error {
+	return h.engine.removeRoute(h, method, pattern)
+}
+
+// Unmount 删除 Mount 挂载的 http.Handler
+func (e *Engine) Unmount(prefix string) error {
+	pattern := strings.TrimRight(prefix, "/") + "/*"
+	return e.updateRoutes(func(t *routeTable) error {
+		for i, route := range t.mounts {
+			if route.Pattern == pattern {
+				t.mounts = append(t.mounts[:i:i], t.mounts[i+1:]...)
+				t.rebuildNames()
+				return nil
+			}
+		}
+		return ErrorRouteNotFound
+	})
+}
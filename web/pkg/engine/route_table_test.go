@@ -0,0 +1,203 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_RemoveRoute(t *testing.T) {
+	e := New()
+	handler := func(c *Context) {
+		c.StringOk(c.FullPath())
+	}
+	e.GET("/user/:id<int>", handler, WithName("user"))
+	e.GET("/user/:id<int>/profile", handler)
+	e.GET("/static/*", handler)
+	assert.Nil(t, e.Any("/any", handler, WithName("any")))
+	e.Host("api.example.com").GET("/", handler)
+	assert.Nil(t, e.Mount("/legacy", http.NotFoundHandler(), WithName("legacy")))
+
+	serve := func(host string, path string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if host != "" {
+			req.Host = host
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Nil(t, e.RemoveRoute(http.MethodGet, "/user/:id<int>"))
+	assert.Equal(t, http.StatusNotFound, serve("", "/user/1"))
+	assert.Equal(t, http.StatusOK, serve("", "/user/1/profile"))
+	assert.Equal(t, ErrorRouteNotFound, e.RemoveRoute(http.MethodGet, "/user/:id<int>"))
+	_, err := e.URL("user", "id", "1")
+	assert.Equal(t, ErrorRouteNameNotFound, err)
+
+	// 中间节点没有路由和子节点时一起删除
+	assert.Nil(t, e.RemoveRoute(http.MethodGet, "/user/:id<int>/profile"))
+	_, found := e.table.Load().router.(*TreeBasedRouter).routeForest[http.MethodGet].childForPattern("user")
+	assert.False(t, found)
+
+	assert.Nil(t, e.RemoveRoute(http.MethodGet, "/static/*"))
+	assert.Equal(t, http.StatusNotFound, serve("", "/static/app.js"))
+
+	// Any 注册的路由删除一个方法后名字仍然有效
+	assert.Nil(t, e.RemoveRoute(http.MethodGet, "/any"))
+	url, err := e.URL("any")
+	assert.Nil(t, err)
+	assert.Equal(t, "/any", url)
+
+	assert.Equal(t, ErrorRouteNotFound, e.Host("other.example.com").RemoveRoute(http.MethodGet, "/"))
+	assert.Nil(t, e.Host("api.example.com").RemoveRoute(http.MethodGet, "/"))
+	assert.Equal(t, http.StatusNotFound, serve("api.example.com", "/"))
+
+	assert.Nil(t, e.Unmount("/legacy/"))
+	assert.Equal(t, ErrorRouteNotFound, e.Unmount("/legacy"))
+	_, err = e.URL("legacy")
+	assert.Equal(t, ErrorRouteNameNotFound, err)
+
+	// 名字重复时路由不会添加
+	assert.Equal(t, ErrorDuplicateRouteName, e.AddRoute(http.MethodGet, "/other", handler, WithName("any")))
+	assert.Equal(t, http.StatusNotFound, serve("", "/other"))
+}
+
+// TestEngine_ConcurrentRouteChanges 处理请求的同时添加和删除路由，使用 go test -race 检查数据竞争
+func TestEngine_ConcurrentRouteChanges(t *testing.T) {
+	e := New()
+	e.GET("/stable/:id", func(c *Context) {
+		c.StringOk(c.PathParams["id"])
+	})
+
+	var stop atomic.Bool
+	var served atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for !stop.Load() {
+				w := httptest.NewRecorder()
+				e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/stable/%d", i), nil))
+				if w.Code != http.StatusOK || w.Body.String() != fmt.Sprint(i) {
+					t.Errorf("stable route broken: %d %s", w.Code, w.Body.String())
+					return
+				}
+				// 动态路由可能存在也可能不存在，只要求不出错
+				w = httptest.NewRecorder()
+				e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/plugin/%d/run", i), nil))
+				if w.Code != http.StatusOK && w.Code != http.StatusNotFound {
+					t.Errorf("unexpected status %d", w.Code)
+					return
+				}
+				_ = e.Routes()
+				served.Add(1)
+			}
+		}(i)
+	}
+
+	for round := 0; round < 50; round++ {
+		for i := 0; i < 8; i++ {
+			pattern := fmt.Sprintf("/plugin/%d/run", i)
+			assert.Nil(t, e.AddRoute(http.MethodGet, pattern, func(c *Context) {
+				c.StringOk("plugin")
+			}, WithName(pattern)))
+			assert.Nil(t, e.Host(fmt.Sprintf("t%d.example.com", i)).AddRoute(http.MethodGet, pattern, func(c *Context) {}))
+		}
+		for i := 0; i < 8; i++ {
+			pattern := fmt.Sprintf("/plugin/%d/run", i)
+			assert.Nil(t, e.RemoveRoute(http.MethodGet, pattern))
+			assert.Nil(t, e.Host(fmt.Sprintf("t%d.example.com", i)).RemoveRoute(http.MethodGet, pattern))
+		}
+		// 全局中间件也可以在处理请求时添加
+		e.Use(func(c *Context) {})
+	}
+	// 路由变更可能在读协程跑起来之前就结束了，至少等它们处理过一轮请求
+	for served.Load() == 0 {
+		runtime.Gosched()
+	}
+	stop.Store(true)
+	wg.Wait()
+	assert.True(t, served.Load() > 0)
+	assert.Equal(t, 1, len(e.Routes()))
+}
+
+func TestEngine_UpdateRoutes(t *testing.T) {
+	e := New()
+	handler := func(c *Context) {
+		c.StringOk(c.FullPath())
+	}
+	e.GET("/old", handler, WithName("old"))
+	api := e.Host("api.example.com")
+	serve := func(host string, path string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Host = host
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 出错时前面的删除和添加都不生效
+	err := e.UpdateRoutes(func(b *RouteBatch) error {
+		assert.Nil(t, b.RemoveRoute(nil, http.MethodGet, "/old"))
+		assert.Nil(t, b.AddRoute(api, http.MethodGet, "/new", handler))
+		return b.AddRoute(nil, http.MethodGet, "/bad/:id<float>", handler)
+	})
+	assert.Equal(t, ErrorUnknownParamType, err)
+	assert.Equal(t, http.StatusOK, serve("example.com", "/old"))
+	assert.Equal(t, http.StatusNotFound, serve("api.example.com", "/new"))
+	_, err = e.URL("old")
+	assert.Nil(t, err)
+
+	// 同一批里删除旧路由之后，名字可以给新路由用
+	assert.Nil(t, e.UpdateRoutes(func(b *RouteBatch) error {
+		if err := b.RemoveRoute(nil, http.MethodGet, "/old"); err != nil {
+			return err
+		}
+		if err := b.AddRoute(api, http.MethodGet, "/new", handler); err != nil {
+			return err
+		}
+		return b.AddRoute(nil, http.MethodGet, "/other", handler, WithName("old"))
+	}))
+	assert.Equal(t, http.StatusNotFound, serve("example.com", "/old"))
+	assert.Equal(t, http.StatusOK, serve("api.example.com", "/new"))
+	url, err := e.URL("old")
+	assert.Nil(t, err)
+	assert.Equal(t, "/other", url)
+	assert.Len(t, e.Routes(), 2)
+}
+
+func TestTreeBasedRouter_CloneSharesNodes(t *testing.T) {
+	router := NewTreeBasedRouter()
+	handler := func(c *Context) {}
+	for _, pattern := range []string{"/a/b", "/a/c", "/d/:id"} {
+		assert.Nil(t, router.AddRoute(http.MethodGet, pattern, handler))
+	}
+	original := router.(*TreeBasedRouter).routeForest[http.MethodGet]
+
+	clone := router.Clone()
+	assert.Nil(t, clone.AddRoute(http.MethodGet, "/a/e", handler))
+	assert.True(t, clone.RemoveRoute(http.MethodGet, "/d/:id"))
+	assert.Nil(t, clone.AddRoute(http.MethodGet, "/a/e/f", handler))
+
+	// 原路由树不受影响
+	assert.Equal(t, 3, len(router.Routes()))
+	assert.Same(t, original, router.(*TreeBasedRouter).routeForest[http.MethodGet])
+	assert.Equal(t, 2, len(original.children))
+	assert.Equal(t, 2, len(original.children[0].children))
+	assert.Equal(t, 4, len(clone.Routes()))
+
+	// 没有修改的子树共享，只复制修改路径上的节点
+	cloned := clone.(*TreeBasedRouter).routeForest[http.MethodGet]
+	assert.NotSame(t, original, cloned)
+	assert.Equal(t, 1, len(cloned.children))
+	assert.NotSame(t, original.children[0], cloned.children[0])
+	assert.Same(t, original.children[0].children[0], cloned.children[0].children[0])
+}
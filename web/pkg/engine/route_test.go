@@ -0,0 +1,40 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithTimeout(t *testing.T) {
+	e := New()
+	e.GET("/slow", func(c *Context) {
+		<-c.Done()
+	}, WithTimeout(10*time.Millisecond))
+	e.GET("/written", func(c *Context) {
+		c.StringOk("partial")
+		<-c.R.Context().Done()
+	}, WithTimeout(10*time.Millisecond))
+	e.GET("/fast", func(c *Context) {
+		_, ok := c.R.Context().Deadline()
+		assert.True(t, ok)
+		c.StringOk("ok")
+	}, WithTimeout(time.Second))
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	// 已经写了响应不再改成 503
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/written", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "partial", w.Body.String())
+
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	assert.Equal(t, "ok", w.Body.String())
+}
@@ -5,34 +5,63 @@ import "net/http"
 // Router 定义路由接口，可以用不同的实现，可以基于 map 和 前缀树的实现
 type Router interface {
 	ServerHTTP(c * Context)
+	// Routes 返回所有注册的路由
+	Routes() []*Route
+	// RemoveRoute 删除路由，pattern 和注册时一致，路由不存在返回 false
+	RemoveRoute(method string, pattern string) bool
+	// Clone 复制路由器，Engine 修改路由时先复制再修改，修改副本不能影响正在使用的路由器
+	Clone() Router
 	Routable
 }
 
 func NewMapBasedRouter() Router {
 	router := &MapBasedRouter{
-		handlers: make(map[string]HandlerFunc),
+		handlers: make(map[string]*Route),
 	}
 	return router
 }
 
 type MapBasedRouter struct {
-	handlers map[string]HandlerFunc
+	handlers map[string]*Route
 }
 
 
 func (m *MapBasedRouter) ServerHTTP(c *Context) {
 	routeKey := c.Method + "-" + c.Path
-	handler, ok := m.handlers[routeKey]
+	route, ok := m.handlers[routeKey]
 	if !ok {
-		c.StringFormat(http.StatusNotFound, "Not Found Method: %s Path: %s", c.Method, c.Path)
+		c.handlers = append(c.handlers, notFoundHandler)
+		c.Next()
 		return
 	}
-	handler(c)
+	c.serveRoute(route)
 }
 
-func (m *MapBasedRouter) AddRoute(method string, pattern string, handlerFunc HandlerFunc) error {
+func (m *MapBasedRouter) AddRoute(method string, pattern string, handlerFunc HandlerFunc, opts ...RouteOption) error {
 	routeKey := method + "-" + pattern
-	m.handlers[routeKey] = handlerFunc
+	m.handlers[routeKey] = newRoute(method, pattern, handlerFunc, opts...)
 	return nil
 }
 
+func (m *MapBasedRouter) RemoveRoute(method string, pattern string) bool {
+	routeKey := method + "-" + pattern
+	if _, ok := m.handlers[routeKey]; !ok {
+		return false
+	}
+	delete(m.handlers, routeKey)
+	return true
+}
+
+func (m *MapBasedRouter) Clone() Router {
+	handlers := make(map[string]*Route, len(m.handlers))
+	for k, v := range m.handlers {
+		handlers[k] = v
+	}
+	return &MapBasedRouter{handlers: handlers}
+}
+
+// notFoundHandler 没有命中路由时执行
+func notFoundHandler(c *Context) {
+	c.StringFormat(http.StatusNotFound, "Not Found Method: %s Path: %s", c.Method, c.Path)
+}
+
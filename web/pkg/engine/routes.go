@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// RouteInfo 路由描述信息，用于打印路由表和调试接口
+type RouteInfo struct {
+	Method  string `json:"method"`
+	Pattern string `json:"pattern"`
+	// Host 路由所属 Host 模式，参考 Engine.Host
+	Host string `json:"host,omitempty"`
+	// Name 路由名字，参考 WithName
+	Name string `json:"name,omitempty"`
+	// Handler 处理函数名字，比如 github.com/xxx/api/v3.GetHeaders
+	Handler string `json:"handler"`
+	// Middlewares 命中该路由时执行的中间件名字，先全局中间件，后路由中间件
+	Middlewares []string `json:"middlewares"`
+	// Meta 路由元数据，文档生成等工具读取，不输出到调试接口
+	Meta map[string]any `json:"-"`
+}
+
+// Routes 返回已经注册的路由，按 pattern 和方法排序，挂载的 http.Handler 方法为 *
+func (e *Engine) Routes() []RouteInfo {
+	table := e.table.Load()
+	routes := table.allRoutes()
+	sortRoutes(routes)
+	infos := make([]RouteInfo, 0, len(routes))
+	for _, route := range routes {
+		middlewares := make([]string, 0, len(table.middlewares)+len(route.Middlewares))
+		for _, m := range table.middlewares {
+			middlewares = append(middlewares, nameOfFunction(m))
+		}
+		for _, m := range route.Middlewares {
+			middlewares = append(middlewares, nameOfFunction(m))
+		}
+		infos = append(infos, RouteInfo{
+			Method:      route.Method,
+			Pattern:     route.Pattern,
+			Host:        route.Host,
+			Name:        route.Name,
+			Handler:     nameOfFunction(route.Handler),
+			Middlewares: middlewares,
+			Meta:        route.Meta,
+		})
+	}
+	return infos
+}
+
+// WriteRoutes 按文本表格输出路由表
+func (e *Engine) WriteRoutes(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "HOST\tMETHOD\tPATTERN\tHANDLER\tMIDDLEWARES")
+	for _, route := range e.Routes() {
+		middlewares := strings.Join(route.Middlewares, ",")
+		if middlewares == "" {
+			middlewares = "-"
+		}
+		host := route.Host
+		if host == "" {
+			host = "*"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", host, route.Method, route.Pattern, route.Handler, middlewares)
+	}
+	return tw.Flush()
+}
+
+// RoutesHandler 返回输出路由表的处理函数，用于注册调试接口，比如
+// e.GET("/debug/routes", e.RoutesHandler())
+// 请求参数 format=json 或者 Accept 包含 application/json 时返回 JSON，否则返回文本表格
+func (e *Engine) RoutesHandler() HandlerFunc {
+	return func(c *Context) {
+		if c.Query("format") == "json" || strings.Contains(c.GetHeader("Accept"), "application/json") {
+			_ = c.OKJson(e.Routes())
+			return
+		}
+		buf := &bytes.Buffer{}
+		_ = e.WriteRoutes(buf)
+		c.StringOk(buf.String())
+	}
+}
+
+// printRoutes Debug 模式启动时打印路由表
+func (e *Engine) printRoutes() {
+	buf := &bytes.Buffer{}
+	_ = e.WriteRoutes(buf)
+	log.Printf("[debug] registered routes:\n%s", buf.String())
+}
+
+// Routes 返回所有注册的路由
+func (t *TreeBasedRouter) Routes() []*Route {
+	routes := make([]*Route, 0)
+	for _, method := range supportedMethods {
+		routes = collectRoutes(t.routeForest[method], routes)
+	}
+	sortRoutes(routes)
+	return routes
+}
+
+func collectRoutes(n *node, routes []*Route) []*Route {
+	if n.end && n.route != nil {
+		routes = append(routes, n.route)
+	}
+	for _, child := range n.children {
+		routes = collectRoutes(child, routes)
+	}
+	return routes
+}
+
+// Routes 返回所有注册的路由
+func (m *MapBasedRouter) Routes() []*Route {
+	routes := make([]*Route, 0, len(m.handlers))
+	for _, route := range m.handlers {
+		routes = append(routes, route)
+	}
+	sortRoutes(routes)
+	return routes
+}
+
+func sortRoutes(routes []*Route) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Host != routes[j].Host {
+			return routes[i].Host < routes[j].Host
+		}
+		if routes[i].Pattern != routes[j].Pattern {
+			return routes[i].Pattern < routes[j].Pattern
+		}
+		return methodOrder(routes[i].Method) < methodOrder(routes[j].Method)
+	})
+}
+
+// methodOrder 同一个 pattern 的路由按 supportedMethods 顺序输出
+func methodOrder(method string) int {
+	for i, m := range supportedMethods {
+		if m == method {
+			return i
+		}
+	}
+	return len(supportedMethods)
+}
+
+// nameOfFunction 返回函数名字，闭包返回外层函数名字加 .funcN 后缀
+func nameOfFunction(f any) string {
+	if f == nil {
+		return ""
+	}
+	v := reflect.ValueOf(f)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return ""
+	}
+	fn := runtime.FuncForPC(v.Pointer())
+	if fn == nil {
+		return ""
+	}
+	return fn.Name()
+}
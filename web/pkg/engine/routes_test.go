@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func routesTestHandler(c *Context) {}
+
+func routesTestMiddleware(c *Context) {}
+
+func TestEngine_Routes(t *testing.T) {
+	e := New()
+	e.Use(routesTestMiddleware)
+	e.POST("/user", routesTestHandler)
+	e.GET("/user", routesTestHandler, WithMiddlewares(routesTestMiddleware))
+	e.GET("/user/:id", routesTestHandler)
+	e.GET("/static/*", func(c *Context) {})
+
+	routes := e.Routes()
+	assert.Equal(t, 4, len(routes))
+	assert.Equal(t, "/static/*", routes[0].Pattern)
+	assert.True(t, strings.HasPrefix(routes[0].Handler, "github.com/2456868764/go-learning/web/pkg/engine.TestEngine_Routes.func"))
+	assert.Equal(t, RouteInfo{
+		Method:  http.MethodGet,
+		Pattern: "/user",
+		Handler: "github.com/2456868764/go-learning/web/pkg/engine.routesTestHandler",
+		Middlewares: []string{
+			"github.com/2456868764/go-learning/web/pkg/engine.routesTestMiddleware",
+			"github.com/2456868764/go-learning/web/pkg/engine.routesTestMiddleware",
+		},
+	}, routes[1])
+	assert.Equal(t, http.MethodPost, routes[2].Method)
+	assert.Equal(t, 1, len(routes[2].Middlewares))
+	assert.Equal(t, "/user/:id", routes[3].Pattern)
+
+	// map 路由器也支持
+	m := NewMapBasedRouter()
+	_ = m.AddRoute(http.MethodPost, "/b", routesTestHandler)
+	_ = m.AddRoute(http.MethodGet, "/b", routesTestHandler)
+	_ = m.AddRoute(http.MethodGet, "/a", routesTestHandler)
+	mapRoutes := m.Routes()
+	assert.Equal(t, 3, len(mapRoutes))
+	assert.Equal(t, "/a", mapRoutes[0].Pattern)
+	assert.Equal(t, http.MethodGet, mapRoutes[1].Method)
+	assert.Equal(t, http.MethodPost, mapRoutes[2].Method)
+}
+
+func TestEngine_RoutesHandler(t *testing.T) {
+	e := New()
+	e.GET("/user/:id", routesTestHandler)
+	e.GET("/debug/routes", e.RoutesHandler())
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/routes", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Equal(t, 3, len(lines))
+	assert.Equal(t, []string{"HOST", "METHOD", "PATTERN", "HANDLER", "MIDDLEWARES"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"*", "GET", "/user/:id", "github.com/2456868764/go-learning/web/pkg/engine.routesTestHandler", "-"}, strings.Fields(lines[2]))
+
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/routes?format=json", nil))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var routes []RouteInfo
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &routes))
+	assert.Equal(t, 2, len(routes))
+	assert.Equal(t, "/user/:id", routes[1].Pattern)
+}
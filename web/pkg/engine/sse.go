@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrorStreamingUnsupported ResponseWriter 不支持 Flush
+var ErrorStreamingUnsupported = errors.New("streaming unsupported")
+
+// SSEvent 一条 Server-Sent Events 事件
+type SSEvent struct {
+	// ID 事件 ID，客户端重连时通过 Last-Event-ID 请求头带回
+	ID string
+	// Event 事件类型，为空时客户端按 message 处理
+	Event string
+	// Retry 客户端重连间隔，0 表示不设置
+	Retry time.Duration
+	// Data 事件数据，string 和 []byte 原样输出，多行数据按行拆分，其他类型编码成 JSON
+	Data any
+}
+
+// SSEvent 推送一条事件并立即 flush，第一次调用时写入 event-stream 响应头
+func (c *Context) SSEvent(event SSEvent) error {
+	flusher, ok := c.W.(http.Flusher)
+	if !ok {
+		return ErrorStreamingUnsupported
+	}
+	c.startEventStream()
+	if err := writeEvent(c.W, event); err != nil {
+		return err
+	}
+	flusher.Flush()
+	return nil
+}
+
+// LastEventID 客户端重连时带回的最后一个事件 ID
+func (c *Context) LastEventID() string {
+	return c.GetHeader("Last-Event-ID")
+}
+
+// Stream 循环调用 step 写入数据，每次调用后 flush，step 返回 false 时结束并返回 false
+// 客户端断开或者服务关闭时结束并返回 true，step 里阻塞等待数据时应该同时监听 c.Done()
+func (c *Context) Stream(step func(w io.Writer) bool) bool {
+	flusher, _ := c.W.(http.Flusher)
+	done := c.Done()
+	for {
+		select {
+		case <-done:
+			return true
+		default:
+			keepOpen := step(c.W)
+			if flusher != nil {
+				flusher.Flush()
+			}
+			if !keepOpen {
+				return false
+			}
+		}
+	}
+}
+
+func (c *Context) startEventStream() {
+	header := c.W.Header()
+	if header.Get("Content-Type") == "text/event-stream" {
+		return
+	}
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// 关闭 nginx 代理缓冲
+	header.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+}
+
+// eventFieldReplacer id 和 event 字段不能包含换行
+var eventFieldReplacer = strings.NewReplacer("\n", "", "\r", "")
+
+func writeEvent(w io.Writer, event SSEvent) error {
+	var sb strings.Builder
+	if event.ID != "" {
+		sb.WriteString("id: ")
+		sb.WriteString(eventFieldReplacer.Replace(event.ID))
+		sb.WriteString("\n")
+	}
+	if event.Event != "" {
+		sb.WriteString("event: ")
+		sb.WriteString(eventFieldReplacer.Replace(event.Event))
+		sb.WriteString("\n")
+	}
+	if event.Retry > 0 {
+		sb.WriteString("retry: ")
+		sb.WriteString(strconv.FormatInt(event.Retry.Milliseconds(), 10))
+		sb.WriteString("\n")
+	}
+
+	var data string
+	switch v := event.Data.(type) {
+	case nil:
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		bytes, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		data = string(bytes)
+	}
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		sb.WriteString("data: ")
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
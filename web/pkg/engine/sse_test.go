@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteEvent(t *testing.T) {
+	var sb strings.Builder
+	err := writeEvent(&sb, SSEvent{ID: "1\n", Event: "update", Retry: 3 * time.Second, Data: "line1\nline2"})
+	assert.Nil(t, err)
+	assert.Equal(t, "id: 1\nevent: update\nretry: 3000\ndata: line1\ndata: line2\n\n", sb.String())
+
+	sb.Reset()
+	err = writeEvent(&sb, SSEvent{Data: map[string]int{"count": 1}})
+	assert.Nil(t, err)
+	assert.Equal(t, "data: {\"count\":1}\n\n", sb.String())
+}
+
+func newCounterEngine(exited chan bool) *Engine {
+	e := New()
+	e.GET("/events", func(c *Context) {
+		id, _ := strconv.Atoi(c.LastEventID())
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		exited <- c.Stream(func(w io.Writer) bool {
+			select {
+			case <-c.Done():
+				return false
+			case <-ticker.C:
+				id++
+				return c.SSEvent(SSEvent{ID: strconv.Itoa(id), Data: id}) == nil
+			}
+		})
+	})
+	return e
+}
+
+func TestContext_SSEvent(t *testing.T) {
+	exited := make(chan bool, 1)
+	server := httptest.NewServer(newCounterEngine(exited))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/events", nil)
+	req.Header.Set("Last-Event-ID", "41")
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(resp.Body)
+	lines := make([]string, 0, 3)
+	for len(lines) < 3 {
+		line, err := reader.ReadString('\n')
+		assert.Nil(t, err)
+		if line != "\n" {
+			lines = append(lines, line)
+		}
+	}
+	assert.Equal(t, []string{"id: 42\n", "data: 42\n", "id: 43\n"}, lines)
+
+	// 客户端断开后处理函数退出
+	resp.Body.Close()
+	select {
+	case <-exited:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream did not stop after client disconnect")
+	}
+}
+
+func TestContext_StreamShutdown(t *testing.T) {
+	exited := make(chan bool, 1)
+	e := newCounterEngine(exited)
+	go e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/events", nil))
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Nil(t, e.Shutdown(context.Background()))
+	select {
+	case <-exited:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream did not stop after shutdown")
+	}
+}
+
+func TestContext_DoneWithoutEngine(t *testing.T) {
+	// NewContext 创建的 Context 没有人调用 finish，不能为 Done 启动监听协程
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		c := NewContext(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/events", nil))
+		_ = c.Done()
+	}
+	assert.Less(t, runtime.NumGoroutine()-before, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := NewContext(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx))
+	done := c.Done()
+	assert.Equal(t, done, c.Done())
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("done not closed after request canceled")
+	}
+}
+
+func TestEngine_ShutdownBeforeRun(t *testing.T) {
+	e := New()
+	assert.Nil(t, e.Shutdown(context.Background()))
+	assert.Equal(t, http.ErrServerClosed, e.Run("127.0.0.1:0"))
+
+	// 并发调用 Run 和 Shutdown，Run 总是能返回
+	e = New()
+	result := make(chan error, 1)
+	go func() {
+		result <- e.Run("127.0.0.1:0")
+	}()
+	assert.Nil(t, e.Shutdown(context.Background()))
+	select {
+	case err := <-result:
+		assert.Equal(t, http.ErrServerClosed, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after shutdown")
+	}
+}
@@ -20,6 +20,9 @@ var supportedMethods = [5]string{
 type TreeBasedRouter struct {
 	// 每个支持方法相对应一个前缀树
 	routeForest map[string]*node
+	// Clone 出来的路由器和来源共享节点，修改前复制路径上的节点，owned 记录已经复制、可以直接修改的节点
+	// nil 表示所有节点都属于这个路由器
+	owned map[*node]bool
 }
 
 func NewTreeBasedRouter() Router {
@@ -34,15 +37,24 @@ func NewTreeBasedRouter() Router {
 }
 
 func (t *TreeBasedRouter) ServerHTTP(c *Context) {
-	handler, ok := t.findRoute(c.Method, c.Path, c)
-	if !ok {
-		c.StringFormat(http.StatusNotFound, "Not Found Method: %s Path: %s", c.Method, c.Path)
+	routeNode, ok := t.findRoute(c.Method, c.Path, c)
+	if ok {
+		if checkTrailingSlash(c, routeNode.route) {
+			c.serveRoute(routeNode.route)
+		}
 		return
 	}
-	handler(c)
+	if routerOptions(c).CaseInsensitive {
+		if fixed, found := t.findCaseInsensitivePath(c.Method, c.Path); found {
+			redirectTo(c, fixed)
+			return
+		}
+	}
+	c.handlers = append(c.handlers, notFoundHandler)
+	c.Next()
 }
 
-func (t *TreeBasedRouter) AddRoute(method string, pattern string, handler HandlerFunc) error {
+func (t *TreeBasedRouter) AddRoute(method string, pattern string, handler HandlerFunc, opts ...RouteOption) error {
 	err := validRoutePathPattern(pattern)
 	if err != nil {
 		return err
@@ -54,30 +66,97 @@ func (t *TreeBasedRouter) AddRoute(method string, pattern string, handler Handle
 		return ErrorInvalidRouterMethod
 	}
 
+	route := newRoute(method, pattern, handler, opts...)
+
 	// 把路由分割成数组， 比如/order/detail, 分割成【order, detail]
 	paths := strings.Split(strings.Trim(pattern, "/"), "/")
 
-	currNode := rootNode
+	currNode := t.mutable(rootNode)
+	t.routeForest[method] = currNode
 	for index, path := range paths {
-		child, found := currNode.findChild(path, nil)
-		if found && child.nodeType != nodeTypeAny {
+		child, found := currNode.childForPattern(path)
+		if found {
 			// 找到，继续找
-			currNode = child
+			mutableChild := t.mutable(child)
+			currNode.replaceChild(child, mutableChild)
+			currNode = mutableChild
 		} else {
 			// 没有找到，后面的路由作为当前节点子节点添加，添加完成，返回叶节点，跳出 for 循环
-			currNode = currNode.addChild(paths[index:], handler)
+			currNode = currNode.addChild(paths[index:], route)
 			break
 		}
 	}
 
 	// 到这里, 重新设置节点handler 和 路由节点标志
 	currNode.handler = handler
+	currNode.route = route
 	currNode.end = true
 
 	return nil
 }
 
+// RemoveRoute 删除路由，删除后没有子节点的中间节点一起删除
+func (t *TreeBasedRouter) RemoveRoute(method string, pattern string) bool {
+	rootNode, ok := t.routeForest[method]
+	if !ok {
+		return false
+	}
+	paths := strings.Split(strings.Trim(pattern, "/"), "/")
+	nodes := rootNode.routePath(paths, strings.Trim(pattern, "/"))
+	if nodes == nil {
+		return false
+	}
+	for i, n := range nodes {
+		nodes[i] = t.mutable(n)
+		if i == 0 {
+			t.routeForest[method] = nodes[i]
+		} else {
+			nodes[i-1].replaceChild(n, nodes[i])
+		}
+	}
+	last := nodes[len(nodes)-1]
+	last.end = false
+	last.route = nil
+	last.handler = nil
+	// 没有路由和子节点的中间节点一起删除，根节点保留
+	for i := len(nodes) - 1; i > 0; i-- {
+		if nodes[i].end || len(nodes[i].children) > 0 {
+			break
+		}
+		nodes[i-1].removeChild(nodes[i])
+	}
+	return true
+}
+
+// Clone 复制路由器，节点在新旧路由器之间共享，AddRoute 和 RemoveRoute 只复制修改路径上的节点
+// 修改一个路由的开销和路由总数无关
+func (t *TreeBasedRouter) Clone() Router {
+	forest := make(map[string]*node, len(t.routeForest))
+	for method, root := range t.routeForest {
+		forest[method] = root
+	}
+	return &TreeBasedRouter{routeForest: forest, owned: make(map[*node]bool)}
+}
+
+// mutable 返回可以修改的节点，共享节点第一次修改时复制
+func (t *TreeBasedRouter) mutable(n *node) *node {
+	if t.owned == nil || t.owned[n] {
+		return n
+	}
+	cp := n.copy()
+	t.owned[cp] = true
+	return cp
+}
+
 func validRoutePathPattern(pattern string) error {
+	for _, segment := range strings.Split(pattern, "/") {
+		if strings.HasPrefix(segment, ":") {
+			if err := validParamSegment(segment); err != nil {
+				return err
+			}
+		}
+	}
+
 	// 目前只接受 /* 这个路由风格， * 必须是最后一个字符，同时前一个字符是 /
 	starPos := strings.Index(pattern, "*")
 	if starPos > 0 {
@@ -92,7 +171,7 @@ func validRoutePathPattern(pattern string) error {
 	return nil
 }
 
-func (t *TreeBasedRouter) findRoute(method string, path string, c *Context) (HandlerFunc, bool) {
+func (t *TreeBasedRouter) findRoute(method string, path string, c *Context) (*node, bool) {
 	paths := strings.Split(strings.Trim(path, "/"), "/")
 
 	// 方法不支持
@@ -101,22 +180,25 @@ func (t *TreeBasedRouter) findRoute(method string, path string, c *Context) (Han
 		return nil, false
 	}
 
-	currNode := rootNode
-	for _, subPath := range paths {
-		child, found := currNode.findChild(subPath, c)
-		if !found {
-			return nil, false
-		}
-		currNode = child
+	// 匹配过程中不写参数，多个参数节点共用时参数名以命中路由的 pattern 为准
+	// 比如注册了 /order/detail/info 但是访问 /order，没有命中路由
+	currNode, found := rootNode.match(paths)
+	if !found {
+		return nil, false
 	}
 
-	// 找到了，判断一下是否注册的路由
-	if !currNode.end {
-		// 比如这种场景
-		// 比如注册了 /order/detail/info
-		// 但是访问 /order
-		return nil, false
+	if c != nil {
+		setPathParams(c, currNode.route.Pattern, paths)
 	}
+	return currNode, true
+}
 
-	return currNode.handler, true
+// setPathParams 按路由 pattern 把参数值写入 c.PathParams
+func setPathParams(c *Context, pattern string, paths []string) {
+	for i, segment := range strings.Split(strings.Trim(pattern, "/"), "/") {
+		if strings.HasPrefix(segment, ":") && i < len(paths) {
+			name, _ := ParseParam(segment)
+			c.PathParams[name] = paths[i]
+		}
+	}
 }
@@ -0,0 +1,286 @@
+package engine
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultMultipartMemory 解析表单时默认最多使用 32MB 内存，超过的部分写入临时文件
+const defaultMultipartMemory = 32 << 20
+
+// sniffLength http.DetectContentType 最多读取的字节数
+const sniffLength = 512
+
+var ErrorFileTooLarge = errors.New("upload file too large")
+var ErrorFileTypeNotAllowed = errors.New("upload file type not allowed")
+
+// UploadOptions 文件上传配置
+type UploadOptions struct {
+	// MaxMemory 解析表单时使用内存的上限，超过的文件内容写入临时文件，0 使用默认 32MB
+	MaxMemory int64
+	// MaxFileSize 单个文件大小限制，0 表示不限制，读取文件内容时检查，超过立即停止解析
+	MaxFileSize int64
+	// MaxTotalSize 整个上传请求大小限制，0 表示不限制，超过返回 ErrorRequestBodyTooLarge
+	MaxTotalSize int64
+	// AllowedTypes 允许的文件类型，根据文件内容嗅探，不信任客户端声明的类型
+	// 支持完整类型比如 image/png 和通配比如 image/*，为空表示不限制
+	AllowedTypes []string
+}
+
+// UploadPart 流式处理的一个表单项
+type UploadPart struct {
+	// FieldName 表单字段名
+	FieldName string
+	// FileName 文件名，普通字段为空
+	FileName string
+	// ContentType 根据内容嗅探的类型，普通字段为空
+	ContentType string
+	// Reader 表单项内容，文件超过 MaxFileSize 时读取返回 ErrorFileTooLarge
+	io.Reader
+
+	header textproto.MIMEHeader
+}
+
+func (c *Context) uploadOptions() UploadOptions {
+	var options UploadOptions
+	if c.engine != nil {
+		options = c.engine.Upload
+	}
+	if c.route != nil && c.route.Upload != nil {
+		options = *c.route.Upload
+	}
+	if options.MaxMemory <= 0 {
+		options.MaxMemory = defaultMultipartMemory
+	}
+	return options
+}
+
+// limitUploadBody 限制整个上传请求的大小
+func (c *Context) limitUploadBody(options UploadOptions) {
+	if options.MaxTotalSize > 0 {
+		c.limitBody(options.MaxTotalSize)
+	}
+}
+
+// MultipartForm 解析 multipart 表单，并按上传配置校验每个文件的大小和类型
+// 和 StreamMultipart 一样边读边校验，文件超过 MaxFileSize 时立即停止解析
+// 解析或者校验失败后再次调用返回同一个错误
+func (c *Context) MultipartForm() (*multipart.Form, error) {
+	if c.multipartErr != nil {
+		return nil, c.multipartErr
+	}
+	if c.R.MultipartForm != nil {
+		return c.R.MultipartForm, nil
+	}
+	options := c.uploadOptions()
+	c.limitUploadBody(options)
+	form, err := c.readMultipartForm(options)
+	if err != nil {
+		// MultipartReader 已经标记了 R.MultipartForm，失败时清掉
+		c.R.MultipartForm = nil
+		c.multipartErr = err
+		return nil, err
+	}
+
+	if c.R.Form == nil {
+		_ = c.R.ParseForm()
+	}
+	if c.R.PostForm == nil {
+		c.R.PostForm = make(url.Values)
+	}
+	for k, v := range form.Value {
+		c.R.Form[k] = append(c.R.Form[k], v...)
+		c.R.PostForm[k] = append(c.R.PostForm[k], v...)
+	}
+	c.R.MultipartForm = form
+	return form, nil
+}
+
+// readMultipartForm 把校验过的表单项重新编码后交给 ReadForm，
+// 文件内容仍然按 MaxMemory 放在内存或者临时文件里，校验失败时 ReadForm 立即返回并删除临时文件
+func (c *Context) readMultipartForm(options UploadOptions) (*multipart.Form, error) {
+	reader, err := c.R.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	done := make(chan error, 1)
+	go func() {
+		err := readParts(reader, options, func(part *UploadPart) error {
+			dst, err := writer.CreatePart(part.header)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(dst, part)
+			return err
+		})
+		if err == nil {
+			err = writer.Close()
+		}
+		_ = pw.CloseWithError(err)
+		done <- err
+	}()
+
+	form, err := multipart.NewReader(pr, writer.Boundary()).ReadForm(options.MaxMemory)
+	// ReadForm 自己出错时让写入的协程退出
+	_ = pr.CloseWithError(err)
+	// 校验失败时 ReadForm 返回的是包装过的错误，以写入的协程为准
+	if writeErr := <-done; writeErr != nil {
+		err = writeErr
+	}
+	if err != nil {
+		if form != nil {
+			_ = form.RemoveAll()
+		}
+		return nil, uploadError(err)
+	}
+	return form, nil
+}
+
+// FormFile 返回表单里名字为 name 的第一个文件
+func (c *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	files := form.File[name]
+	if len(files) == 0 {
+		return nil, http.ErrMissingFile
+	}
+	return files[0], nil
+}
+
+// SaveUploadedFile 保存上传文件到 dst，目录不存在时自动创建
+func (c *Context) SaveUploadedFile(file *multipart.FileHeader, dst string) error {
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err = os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, src)
+	return err
+}
+
+// StreamMultipart 流式处理 multipart 表单，每个表单项依次交给 handle，不会缓存整个上传内容
+// handle 需要在返回前读取完表单项内容，返回错误时停止处理
+func (c *Context) StreamMultipart(handle func(part *UploadPart) error) error {
+	options := c.uploadOptions()
+	c.limitUploadBody(options)
+	reader, err := c.R.MultipartReader()
+	if err != nil {
+		return err
+	}
+	return readParts(reader, options, handle)
+}
+
+// readParts 依次读取表单项，文件按上传配置嗅探类型并限制大小
+func readParts(reader *multipart.Reader, options UploadOptions, handle func(part *UploadPart) error) error {
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return uploadError(err)
+		}
+
+		uploadPart := &UploadPart{FieldName: part.FormName(), FileName: part.FileName(), Reader: part, header: part.Header}
+		if uploadPart.FileName != "" {
+			buffered := bufio.NewReaderSize(part, sniffLength)
+			head, err := buffered.Peek(sniffLength)
+			if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+				return uploadError(err)
+			}
+			uploadPart.ContentType = detectContentType(head)
+			if !typeAllowed(uploadPart.ContentType, options.AllowedTypes) {
+				return ErrorFileTypeNotAllowed
+			}
+			uploadPart.Reader = &limitedFileReader{reader: buffered, remaining: options.MaxFileSize, limited: options.MaxFileSize > 0}
+		}
+
+		// 出错时不调用 Close，Close 会把表单项剩下的内容读完
+		if err = handle(uploadPart); err != nil {
+			return uploadError(err)
+		}
+		part.Close()
+	}
+}
+
+func uploadError(err error) error {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return ErrorRequestBodyTooLarge
+	}
+	return err
+}
+
+// detectContentType 嗅探内容类型，去掉 charset 等参数
+func detectContentType(head []byte) string {
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return mediaType
+}
+
+func typeAllowed(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, t := range allowed {
+		if t == contentType {
+			return true
+		}
+		if strings.HasSuffix(t, "/*") && strings.HasPrefix(contentType, t[:len(t)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// limitedFileReader 读取超过 remaining 时返回 ErrorFileTooLarge
+type limitedFileReader struct {
+	reader    io.Reader
+	remaining int64
+	limited   bool
+}
+
+func (l *limitedFileReader) Read(p []byte) (int, error) {
+	if !l.limited {
+		return l.reader.Read(p)
+	}
+	if l.remaining < 0 {
+		return 0, ErrorFileTooLarge
+	}
+	// 多读一个字节，用来判断是否超过限制
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.reader.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n + int(l.remaining), ErrorFileTooLarge
+	}
+	return n, err
+}
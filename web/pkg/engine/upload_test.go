@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var pngHeader = []byte("\x89PNG\x0D\x0A\x1A\x0A")
+
+func newMultipartRequest(t *testing.T, path string, files map[string][]byte) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	assert.Nil(t, writer.WriteField("title", "avatar"))
+	for name, content := range files {
+		part, err := writer.CreateFormFile(name, name+".bin")
+		assert.Nil(t, err)
+		_, err = part.Write(content)
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, writer.Close())
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestContext_FormFile(t *testing.T) {
+	dir := t.TempDir()
+	e := New()
+	e.POST("/upload", func(c *Context) {
+		file, err := c.FormFile("avatar")
+		if err != nil {
+			c.StringFormat(http.StatusBadRequest, "%v", err)
+			return
+		}
+		if err = c.SaveUploadedFile(file, filepath.Join(dir, "avatars", file.Filename)); err != nil {
+			c.StringFormat(http.StatusInternalServerError, "%v", err)
+			return
+		}
+		c.StringOk(c.PostForm("title"))
+	}, WithUploadOptions(UploadOptions{MaxFileSize: 64, AllowedTypes: []string{"image/*"}}))
+
+	png := append(append([]byte{}, pngHeader...), 1, 2, 3)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, newMultipartRequest(t, "/upload", map[string][]byte{"avatar": png}))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "avatar", w.Body.String())
+	saved, err := os.ReadFile(filepath.Join(dir, "avatars", "avatar.bin"))
+	assert.Nil(t, err)
+	assert.Equal(t, png, saved)
+
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, newMultipartRequest(t, "/upload", map[string][]byte{"avatar": []byte("plain text")}))
+	assert.Equal(t, ErrorFileTypeNotAllowed.Error(), w.Body.String())
+
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, newMultipartRequest(t, "/upload", map[string][]byte{"avatar": append(png, make([]byte, 64)...)}))
+	assert.Equal(t, ErrorFileTooLarge.Error(), w.Body.String())
+
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, newMultipartRequest(t, "/upload", map[string][]byte{"other": png}))
+	assert.Equal(t, http.ErrMissingFile.Error(), w.Body.String())
+
+	// 校验失败后再次读取仍然返回错误，不会拿到已经删除临时文件的表单
+	e.POST("/retry", func(c *Context) {
+		_, err := c.FormFile("avatar")
+		assert.Equal(t, ErrorFileTypeNotAllowed, err)
+		_, err = c.MultipartForm()
+		assert.Equal(t, ErrorFileTypeNotAllowed, err)
+		assert.Nil(t, c.R.MultipartForm)
+		_, err = c.FormFile("avatar")
+		assert.Equal(t, ErrorFileTypeNotAllowed, err)
+	}, WithUploadOptions(UploadOptions{AllowedTypes: []string{"image/*"}}))
+	e.ServeHTTP(httptest.NewRecorder(), newMultipartRequest(t, "/retry", map[string][]byte{"avatar": []byte("plain text")}))
+}
+
+// countingReader 记录已经读取的字节数
+type countingReader struct {
+	io.Reader
+	n int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += n
+	return n, err
+}
+
+func TestContext_MultipartFormStopsAtMaxFileSize(t *testing.T) {
+	e := New()
+	e.POST("/upload", func(c *Context) {
+		_, err := c.MultipartForm()
+		assert.Equal(t, ErrorFileTooLarge, err)
+	}, WithUploadOptions(UploadOptions{MaxMemory: 1 << 10, MaxFileSize: 1 << 10}))
+
+	req := newMultipartRequest(t, "/upload", map[string][]byte{"avatar": make([]byte, 8<<20)})
+	body := &countingReader{Reader: req.Body}
+	req.Body = io.NopCloser(body)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	// 超过限制后立即停止解析，不会把整个文件读进来写入临时文件
+	assert.True(t, body.n < 1<<20, "read %d bytes", body.n)
+}
+
+func TestContext_StreamMultipart(t *testing.T) {
+	e := New()
+	e.Upload = UploadOptions{MaxFileSize: 1024, MaxTotalSize: 4096}
+	e.POST("/upload", func(c *Context) {
+		var parts []string
+		err := c.StreamMultipart(func(part *UploadPart) error {
+			n, err := io.Copy(io.Discard, part)
+			if err != nil {
+				return err
+			}
+			parts = append(parts, part.FieldName+":"+part.ContentType+":"+strconv.FormatInt(n, 10))
+			return nil
+		})
+		if err != nil {
+			c.StringFormat(http.StatusBadRequest, "%v", err)
+			return
+		}
+		c.StringOk(strings.Join(parts, ","))
+	})
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, newMultipartRequest(t, "/upload", map[string][]byte{"file": []byte("hello")}))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "title::6,file:text/plain:5", w.Body.String())
+
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, newMultipartRequest(t, "/upload", map[string][]byte{"file": make([]byte, 2048)}))
+	assert.Equal(t, ErrorFileTooLarge.Error(), w.Body.String())
+
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, newMultipartRequest(t, "/upload", map[string][]byte{"a": make([]byte, 1000), "b": make([]byte, 1000), "c": make([]byte, 1000), "d": make([]byte, 1000), "e": make([]byte, 1000)}))
+	assert.Equal(t, ErrorRequestBodyTooLarge.Error(), w.Body.String())
+}
@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+var ErrorRouteNameNotFound = errors.New("route name not found")
+var ErrorDuplicateRouteName = errors.New("duplicate route name")
+var ErrorMissingRouteParam = errors.New("missing route param")
+var ErrorInvalidURLParams = errors.New("url params must be key value pairs")
+
+// WithName 设置路由名字，通过 Engine.URL 根据名字生成路径，名字必须唯一
+func WithName(name string) RouteOption {
+	return func(route *Route) {
+		route.Name = name
+	}
+}
+
+// URL 根据路由名字生成路径，params 按 key, value 成对传入
+// 路由 pattern 里的 :param 和 * 使用同名参数替换，* 的参数名就是 *，值会做 url 编码
+// * 的值可以包含多段路径，比如 css/app.css，按 / 分段编码
+// 其余参数作为查询参数追加，比如 pattern /user/:id/profile
+// e.URL("profile", "id", "42", "tab", "info") 返回 /user/42/profile?tab=info
+func (e *Engine) URL(name string, params ...string) (string, error) {
+	route, ok := e.table.Load().names[name]
+	if !ok {
+		return "", ErrorRouteNameNotFound
+	}
+	return buildURL(route.Pattern, params...)
+}
+
+// URL 根据路由名字生成路径，参考 Engine.URL
+// NewContext 直接创建的 Context 没有 Engine，返回 ErrorRouteNameNotFound
+func (c *Context) URL(name string, params ...string) (string, error) {
+	if c.engine == nil {
+		return "", ErrorRouteNameNotFound
+	}
+	return c.engine.URL(name, params...)
+}
+
+func buildURL(pattern string, params ...string) (string, error) {
+	if len(params)%2 != 0 {
+		return "", ErrorInvalidURLParams
+	}
+	values := make(map[string]string, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		values[params[i]] = params[i+1]
+	}
+
+	used := make(map[string]bool)
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		var key string
+		switch {
+		case segment == "*":
+			key = "*"
+		case strings.HasPrefix(segment, ":"):
+			key, _ = ParseParam(segment)
+		default:
+			continue
+		}
+		value, ok := values[key]
+		if !ok || value == "" {
+			return "", ErrorMissingRouteParam
+		}
+		used[key] = true
+		if key == "*" {
+			segments[i] = escapeSegments(value)
+		} else {
+			segments[i] = url.PathEscape(value)
+		}
+	}
+	path := strings.Join(segments, "/")
+
+	// 查询参数按名字排序
+	query := url.Values{}
+	for key, value := range values {
+		if !used[key] {
+			query.Set(key, value)
+		}
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return path, nil
+}
+
+// escapeSegments 按 / 分段编码，保留分隔的 /
+func escapeSegments(value string) string {
+	parts := strings.Split(value, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_URL(t *testing.T) {
+	e := New()
+	handler := func(c *Context) {}
+	assert.Nil(t, e.AddRoute(http.MethodGet, "/user/:id/profile", handler, WithName("profile")))
+	assert.Nil(t, e.AddRoute(http.MethodGet, "/static/*", handler, WithName("static")))
+	assert.Nil(t, e.AddRoute(http.MethodGet, "/", handler, WithName("home")))
+	assert.Equal(t, ErrorDuplicateRouteName, e.AddRoute(http.MethodPost, "/other", handler, WithName("home")))
+	// 名字冲突的路由没有加入路由树
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/other", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, ErrorDuplicateRouteName, e.Mount("/other", http.NotFoundHandler(), WithName("profile")))
+	assert.Equal(t, 3, len(e.Routes()))
+
+	testCases := []struct {
+		name    string
+		route   string
+		params  []string
+		wantURL string
+		wantErr error
+	}{
+		{name: "param", route: "profile", params: []string{"id", "42"}, wantURL: "/user/42/profile"},
+		{name: "escape", route: "profile", params: []string{"id", "a b/c?"}, wantURL: "/user/a%20b%2Fc%3F/profile"},
+		{name: "query", route: "profile", params: []string{"tab", "x&y", "id", "42", "a", "1"}, wantURL: "/user/42/profile?a=1&tab=x%26y"},
+		{name: "any", route: "static", params: []string{"*", "app.js"}, wantURL: "/static/app.js"},
+		{name: "any segments", route: "static", params: []string{"*", "css/a b.css"}, wantURL: "/static/css/a%20b.css"},
+		{name: "root", route: "home", wantURL: "/"},
+		{name: "missing param", route: "profile", params: []string{"tab", "info"}, wantErr: ErrorMissingRouteParam},
+		{name: "empty param", route: "profile", params: []string{"id", ""}, wantErr: ErrorMissingRouteParam},
+		{name: "odd params", route: "profile", params: []string{"id"}, wantErr: ErrorInvalidURLParams},
+		{name: "unknown", route: "unknown", wantErr: ErrorRouteNameNotFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := e.URL(tc.route, tc.params...)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantURL, got)
+		})
+	}
+}
+
+func TestContext_URL(t *testing.T) {
+	e := New()
+	e.GET("/user/:id/profile", func(c *Context) {
+		c.StringOk(c.PathParams["id"])
+	}, WithName("profile"))
+	e.GET("/me", func(c *Context) {
+		location, err := c.URL("profile", "id", "42")
+		assert.Nil(t, err)
+		c.Redirect(http.StatusFound, location)
+	})
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/me", nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/user/42/profile", w.Header().Get("Location"))
+
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/42/profile", nil))
+	assert.Equal(t, "42", w.Body.String())
+
+	// NewContext 直接创建的 Context 没有 Engine
+	c := NewContext(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/me", nil))
+	_, err := c.URL("profile", "id", "42")
+	assert.Equal(t, ErrorRouteNameNotFound, err)
+}
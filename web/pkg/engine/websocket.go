@@ -0,0 +1,13 @@
+package engine
+
+import "github.com/2456868764/go-learning/web/pkg/websocket"
+
+// Upgrade 把当前请求升级为 WebSocket 连接，握手失败时已经写入错误响应
+// 升级成功后不能再通过 Context 写响应，连接由调用方负责关闭
+func (c *Context) Upgrade(options ...websocket.Options) (*websocket.Conn, error) {
+	var opts websocket.Options
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	return websocket.Upgrade(c.W, c.R, opts)
+}
@@ -0,0 +1,36 @@
+package engine
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/2456868764/go-learning/web/pkg/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContext_Upgrade(t *testing.T) {
+	e := New()
+	e.GET("/ws/:room", func(c *Context) {
+		conn, err := c.Upgrade()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(c.PathParams["room"]+":"+string(data)))
+	})
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	conn, _, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws/golang", nil, websocket.Options{})
+	assert.Nil(t, err)
+	defer conn.Close()
+	assert.Nil(t, conn.WriteMessage(websocket.TextMessage, []byte("hello")))
+	_, data, err := conn.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, "golang:hello", string(data))
+}
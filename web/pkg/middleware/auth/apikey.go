@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"strconv"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+)
+
+// APIKeyOptions API key 认证配置
+type APIKeyOptions struct {
+	// Header 读取 API key 的请求头，默认 X-API-Key
+	Header string
+	// Query 不为空时，请求头没有 API key 再从这个查询参数读取
+	Query string
+	// Validator 校验 API key，返回调用方信息，调用方信息保存在 Context.Keys[APIKeyPrincipalKey]
+	Validator func(key string) (principal any, ok bool)
+	// Realm WWW-Authenticate 里返回的 realm
+	Realm string
+}
+
+// APIKey API key 认证
+func APIKey(options APIKeyOptions) engine.HandlerFunc {
+	if options.Validator == nil {
+		panic("auth: APIKeyOptions.Validator is required")
+	}
+	if options.Header == "" {
+		options.Header = "X-API-Key"
+	}
+	realm := options.Realm
+	if realm == "" {
+		realm = "Authorization Required"
+	}
+	challenge := "APIKey realm=" + strconv.Quote(realm) + ", header=" + strconv.Quote(options.Header)
+
+	return func(c *engine.Context) {
+		key := c.GetHeader(options.Header)
+		if key == "" && options.Query != "" {
+			key = c.Query(options.Query)
+		}
+		if key == "" {
+			unauthorized(c, challenge)
+			return
+		}
+		principal, ok := options.Validator(key)
+		if !ok {
+			unauthorized(c, challenge)
+			return
+		}
+		c.Set(APIKeyPrincipalKey, principal)
+	}
+}
+
+// StaticAPIKeys 校验固定 API key 列表的 Validator，keys 是 API key 到调用方名字的映射，使用常量时间比较
+func StaticAPIKeys(keys map[string]string) func(key string) (any, bool) {
+	type entry struct {
+		digest [32]byte
+		name   string
+	}
+	entries := make([]entry, 0, len(keys))
+	for key, name := range keys {
+		entries = append(entries, entry{digest: sha256.Sum256([]byte(key)), name: name})
+	}
+	return func(key string) (any, bool) {
+		digest := sha256.Sum256([]byte(key))
+		var principal any
+		found := false
+		// 遍历全部 key，比较时间和命中哪一个无关
+		for _, e := range entries {
+			if subtle.ConstantTimeCompare(e.digest[:], digest[:]) == 1 {
+				principal, found = e.name, true
+			}
+		}
+		return principal, found
+	}
+}
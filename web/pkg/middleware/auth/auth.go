@@ -0,0 +1,23 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+)
+
+const (
+	// UserKey 认证通过后用户名保存在 Context.Keys 里的 key
+	UserKey = "user"
+	// ClaimsKey JWT 认证通过后 Claims 保存在 Context.Keys 里的 key
+	ClaimsKey = "claims"
+	// APIKeyPrincipalKey API key 认证通过后调用方信息保存在 Context.Keys 里的 key
+	APIKeyPrincipalKey = "api_key_principal"
+)
+
+// unauthorized 认证失败，返回 401 和认证方式，并中断处理链
+func unauthorized(c *engine.Context, challenge string) {
+	c.SetHeader("WWW-Authenticate", challenge)
+	c.StringFormat(http.StatusUnauthorized, "Unauthorized")
+	c.Abort()
+}
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+func newAuthEngine(middleware engine.HandlerFunc) *engine.Engine {
+	e := engine.New()
+	e.GET("/secret", func(c *engine.Context) {
+		c.StringOk(c.GetString(UserKey))
+	}, engine.WithMiddlewares(middleware))
+	return e
+}
+
+func serve(e *engine.Engine, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestBasicAuth(t *testing.T) {
+	e := newAuthEngine(BasicAuthForRealm(Accounts{"jun": "secret"}, "test"))
+
+	req := httptest.NewRequest(http.MethodGet, "/secret", nil)
+	w := serve(e, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Basic realm="test"`, w.Header().Get("WWW-Authenticate"))
+
+	req = httptest.NewRequest(http.MethodGet, "/secret", nil)
+	req.SetBasicAuth("jun", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, serve(e, req).Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/secret", nil)
+	req.SetBasicAuth("jun", "secret")
+	w = serve(e, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "jun", w.Body.String())
+}
+
+func TestJWT(t *testing.T) {
+	now := time.Now()
+	secret := []byte("secret")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	keys := map[string]any{
+		AlgHS256: secret,
+		AlgRS256: &rsaKey.PublicKey,
+		AlgES256: &ecKey.PublicKey,
+	}
+	e := newAuthEngine(JWT(JWTOptions{
+		KeyFunc: func(header JWTHeader) (any, error) {
+			return keys[header.Alg], nil
+		},
+		Issuer:   "go-learning",
+		Audience: "httpbin",
+	}))
+
+	validClaims := Claims{"sub": "jun", "iss": "go-learning", "aud": []string{"httpbin"}, "exp": now.Add(time.Hour).Unix()}
+	signKeys := map[string]any{AlgHS256: secret, AlgRS256: rsaKey, AlgES256: ecKey}
+	for alg, key := range signKeys {
+		token, err := SignJWT(validClaims, alg, key)
+		assert.Nil(t, err)
+		req := httptest.NewRequest(http.MethodGet, "/secret", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := serve(e, req)
+		assert.Equal(t, http.StatusOK, w.Code, alg)
+		assert.Equal(t, "jun", w.Body.String(), alg)
+	}
+
+	testCases := []struct {
+		name    string
+		claims  Claims
+		wantErr error
+	}{
+		{name: "expired", claims: Claims{"iss": "go-learning", "aud": "httpbin", "exp": now.Add(-time.Minute).Unix()}, wantErr: ErrorTokenExpired},
+		{name: "not valid yet", claims: Claims{"iss": "go-learning", "aud": "httpbin", "nbf": now.Add(time.Hour).Unix()}, wantErr: ErrorTokenNotValidYet},
+		{name: "issuer", claims: Claims{"iss": "other", "aud": "httpbin"}, wantErr: ErrorInvalidIssuer},
+		{name: "audience", claims: Claims{"iss": "go-learning", "aud": "other"}, wantErr: ErrorInvalidAudience},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			token, err := SignJWT(tc.claims, AlgHS256, secret)
+			assert.Nil(t, err)
+			req := httptest.NewRequest(http.MethodGet, "/secret", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := serve(e, req)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.True(t, strings.Contains(w.Header().Get("WWW-Authenticate"), tc.wantErr.Error()))
+		})
+	}
+
+	// 篡改签名
+	token, _ := SignJWT(validClaims, AlgHS256, []byte("other"))
+	req := httptest.NewRequest(http.MethodGet, "/secret", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	assert.Equal(t, http.StatusUnauthorized, serve(e, req).Code)
+
+	// alg 和 key 类型不匹配
+	_, err = ParseJWT(mustSign(t, validClaims, AlgHS256, secret), JWTOptions{KeyFunc: StaticKey(&rsaKey.PublicKey)})
+	assert.Equal(t, ErrorUnsupportedAlg, err)
+
+	req = httptest.NewRequest(http.MethodGet, "/secret", nil)
+	w := serve(e, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Bearer realm="Authorization Required"`, w.Header().Get("WWW-Authenticate"))
+}
+
+func mustSign(t *testing.T, claims Claims, alg string, key any) string {
+	token, err := SignJWT(claims, alg, key)
+	assert.Nil(t, err)
+	return token
+}
+
+func TestAPIKey(t *testing.T) {
+	e := newAuthEngine(APIKey(APIKeyOptions{
+		Query:     "api_key",
+		Validator: StaticAPIKeys(map[string]string{"key-1": "service-a"}),
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/secret", nil)
+	w := serve(e, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+
+	req = httptest.NewRequest(http.MethodGet, "/secret", nil)
+	req.Header.Set("X-API-Key", "key-1")
+	assert.Equal(t, http.StatusOK, serve(e, req).Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/secret?api_key=key-1", nil)
+	assert.Equal(t, http.StatusOK, serve(e, req).Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/secret?api_key=key-2", nil)
+	assert.Equal(t, http.StatusUnauthorized, serve(e, req).Code)
+}
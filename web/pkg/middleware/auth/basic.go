@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"strconv"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+)
+
+// Accounts 用户名和密码
+type Accounts map[string]string
+
+// BasicAuth HTTP Basic 认证，realm 为 "Authorization Required"
+func BasicAuth(accounts Accounts) engine.HandlerFunc {
+	return BasicAuthForRealm(accounts, "")
+}
+
+// BasicAuthForRealm HTTP Basic 认证，认证通过后用户名保存在 Context.Keys[UserKey]
+func BasicAuthForRealm(accounts Accounts, realm string) engine.HandlerFunc {
+	if realm == "" {
+		realm = "Authorization Required"
+	}
+	challenge := "Basic realm=" + strconv.Quote(realm)
+
+	// 保存密码摘要，比较摘要保证比较时间和密码长度无关
+	digests := make(map[string][32]byte, len(accounts))
+	for user, password := range accounts {
+		digests[user] = sha256.Sum256([]byte(password))
+	}
+
+	return func(c *engine.Context) {
+		user, password, ok := c.R.BasicAuth()
+		if !ok {
+			unauthorized(c, challenge)
+			return
+		}
+		expected, found := digests[user]
+		actual := sha256.Sum256([]byte(password))
+		if subtle.ConstantTimeCompare(expected[:], actual[:]) != 1 || !found {
+			unauthorized(c, challenge)
+			return
+		}
+		c.Set(UserKey, user)
+	}
+}
@@ -0,0 +1,320 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+)
+
+var ErrorInvalidToken = errors.New("auth: invalid token")
+var ErrorUnsupportedAlg = errors.New("auth: unsupported signing algorithm")
+var ErrorInvalidSignature = errors.New("auth: invalid token signature")
+var ErrorTokenExpired = errors.New("auth: token is expired")
+var ErrorTokenNotValidYet = errors.New("auth: token is not valid yet")
+var ErrorInvalidIssuer = errors.New("auth: invalid token issuer")
+var ErrorInvalidAudience = errors.New("auth: invalid token audience")
+
+// Claims JWT 负载
+type Claims map[string]any
+
+// Subject 返回 sub
+func (c Claims) Subject() string {
+	s, _ := c["sub"].(string)
+	return s
+}
+
+// Issuer 返回 iss
+func (c Claims) Issuer() string {
+	s, _ := c["iss"].(string)
+	return s
+}
+
+// Audience 返回 aud，aud 可以是字符串或者字符串数组
+func (c Claims) Audience() []string {
+	switch aud := c["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []any:
+		audience := make([]string, 0, len(aud))
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				audience = append(audience, s)
+			}
+		}
+		return audience
+	}
+	return nil
+}
+
+// time 读取时间类型的 claim，比如 exp、nbf、iat
+func (c Claims) time(key string) (time.Time, bool, error) {
+	v, ok := c[key]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false, ErrorInvalidToken
+	}
+	seconds, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false, ErrorInvalidToken
+	}
+	return time.Unix(int64(seconds), 0), true, nil
+}
+
+// JWTHeader JWT 头
+type JWTHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// JWTOptions JWT 校验配置
+type JWTOptions struct {
+	// KeyFunc 根据 JWT 头返回校验 key，HS256 返回 []byte，RS256 返回 *rsa.PublicKey，ES256 返回 *ecdsa.PublicKey
+	// key 类型必须和 alg 匹配，防止用公钥当 HMAC 密钥伪造签名
+	KeyFunc func(header JWTHeader) (any, error)
+	// Issuer 不为空时校验 iss
+	Issuer string
+	// Audience 不为空时校验 aud 包含该值
+	Audience string
+	// Leeway 校验 exp、nbf 时允许的时钟偏差
+	Leeway time.Duration
+	// Realm WWW-Authenticate 里返回的 realm
+	Realm string
+	// Now 当前时间，测试使用，默认 time.Now
+	Now func() time.Time
+}
+
+// StaticKey 返回固定 key 的 KeyFunc
+func StaticKey(key any) func(header JWTHeader) (any, error) {
+	return func(header JWTHeader) (any, error) {
+		return key, nil
+	}
+}
+
+// JWT Bearer token 认证，校验签名和 exp、nbf、iss、aud，通过后 Claims 保存在 Context.Keys[ClaimsKey]
+func JWT(options JWTOptions) engine.HandlerFunc {
+	if options.KeyFunc == nil {
+		panic("auth: JWTOptions.KeyFunc is required")
+	}
+	realm := options.Realm
+	if realm == "" {
+		realm = "Authorization Required"
+	}
+
+	return func(c *engine.Context) {
+		token, ok := bearerToken(c.GetHeader("Authorization"))
+		if !ok {
+			unauthorized(c, "Bearer realm="+strconv.Quote(realm))
+			return
+		}
+		claims, err := ParseJWT(token, options)
+		if err != nil {
+			unauthorized(c, fmt.Sprintf("Bearer realm=%q, error=\"invalid_token\", error_description=%q", realm, err.Error()))
+			return
+		}
+		c.Set(ClaimsKey, claims)
+		if sub := claims.Subject(); sub != "" {
+			c.Set(UserKey, sub)
+		}
+	}
+}
+
+func bearerToken(authorization string) (string, bool) {
+	const prefix = "Bearer "
+	if len(authorization) <= len(prefix) || !strings.EqualFold(authorization[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(authorization[len(prefix):]), true
+}
+
+// ParseJWT 解析并校验 JWT
+func ParseJWT(token string, options JWTOptions) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrorInvalidToken
+	}
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrorInvalidToken
+	}
+	header := JWTHeader{}
+	if err = json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, ErrorInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrorInvalidToken
+	}
+	key, err := options.KeyFunc(header)
+	if err != nil {
+		return nil, err
+	}
+	if err = verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrorInvalidToken
+	}
+	claims := Claims{}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err = decoder.Decode(&claims); err != nil {
+		return nil, ErrorInvalidToken
+	}
+	if err = validateClaims(claims, options); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func validateClaims(claims Claims, options JWTOptions) error {
+	now := time.Now()
+	if options.Now != nil {
+		now = options.Now()
+	}
+
+	exp, ok, err := claims.time("exp")
+	if err != nil {
+		return err
+	}
+	if ok && !now.Before(exp.Add(options.Leeway)) {
+		return ErrorTokenExpired
+	}
+	nbf, ok, err := claims.time("nbf")
+	if err != nil {
+		return err
+	}
+	if ok && now.Add(options.Leeway).Before(nbf) {
+		return ErrorTokenNotValidYet
+	}
+	if options.Issuer != "" && claims.Issuer() != options.Issuer {
+		return ErrorInvalidIssuer
+	}
+	if options.Audience != "" {
+		for _, aud := range claims.Audience() {
+			if aud == options.Audience {
+				return nil
+			}
+		}
+		return ErrorInvalidAudience
+	}
+	return nil
+}
+
+func verifySignature(alg string, key any, signingInput []byte, signature []byte) error {
+	digest := sha256.Sum256(signingInput)
+	switch alg {
+	case AlgHS256:
+		secret, ok := key.([]byte)
+		if !ok {
+			return ErrorUnsupportedAlg
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signingInput)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return ErrorInvalidSignature
+		}
+	case AlgRS256:
+		publicKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrorUnsupportedAlg
+		}
+		if rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature) != nil {
+			return ErrorInvalidSignature
+		}
+	case AlgES256:
+		publicKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || publicKey.Curve != elliptic.P256() {
+			return ErrorUnsupportedAlg
+		}
+		// ES256 签名是定长 r||s，各 32 字节
+		if len(signature) != 64 {
+			return ErrorInvalidSignature
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(publicKey, digest[:], r, s) {
+			return ErrorInvalidSignature
+		}
+	default:
+		return ErrorUnsupportedAlg
+	}
+	return nil
+}
+
+// SignJWT 签发 JWT，HS256 key 为 []byte，RS256 key 为 *rsa.PrivateKey，ES256 key 为 *ecdsa.PrivateKey
+func SignJWT(claims Claims, alg string, key any) (string, error) {
+	headerBytes, err := json.Marshal(JWTHeader{Alg: alg, Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerBytes) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch alg {
+	case AlgHS256:
+		secret, ok := key.([]byte)
+		if !ok {
+			return "", ErrorUnsupportedAlg
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signingInput))
+		signature = mac.Sum(nil)
+	case AlgRS256:
+		privateKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return "", ErrorUnsupportedAlg
+		}
+		signature, err = rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+		if err != nil {
+			return "", err
+		}
+	case AlgES256:
+		privateKey, ok := key.(*ecdsa.PrivateKey)
+		if !ok || privateKey.Curve != elliptic.P256() {
+			return "", ErrorUnsupportedAlg
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, privateKey, digest[:])
+		if err != nil {
+			return "", err
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	default:
+		return "", ErrorUnsupportedAlg
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
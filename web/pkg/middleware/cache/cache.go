@@ -0,0 +1,457 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+)
+
+const (
+	// HeaderName 响应头，HIT 命中缓存，STALE 返回旧缓存并在后台更新，MISS 没有命中
+	HeaderName = "X-Cache"
+	// defaultMaxBodyBytes 默认最大缓存响应体
+	defaultMaxBodyBytes = 1 << 20
+)
+
+// Options 缓存配置
+type Options struct {
+	// Store 缓存存储，默认最多 1000 条的 MemoryStore
+	Store Store
+	// TTL 响应没有 Cache-Control max-age 和 Expires 时 200 响应的缓存时间，0 表示这种响应不缓存
+	TTL time.Duration
+	// Headers 加入缓存 key 的请求头，比如 Accept-Language，响应 Vary 的请求头会自动区分
+	Headers []string
+	// MaxBodyBytes 响应体超过时不缓存，默认 1MB
+	MaxBodyBytes int
+}
+
+// cacheableStatus 可以缓存的状态码
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+// revalidateKey 后台更新请求的 context key，跳过缓存查找
+type revalidateKey struct{}
+
+type cache struct {
+	options Options
+	// 正在后台更新的 key，同一个 key 只更新一次
+	revalidating sync.Map
+}
+
+// New 响应缓存中间件，缓存 GET 响应，HEAD 请求使用 GET 的缓存
+// 遵循请求和响应的 Cache-Control、Expires 和 Vary，支持 stale-while-revalidate，
+// 没有 ETag 的 200 响应生成 ETag，If-None-Match 匹配时返回 304
+// POST、PUT、PATCH、DELETE 成功后删除同一路径和查询参数的缓存
+// 中间件会缓冲响应，处理函数调用 Flush 时按流式响应处理，之后的内容直接写出并且不缓存
+func New(options Options) engine.HandlerFunc {
+	if options.Store == nil {
+		options.Store = NewMemoryStore(1000, 0)
+	}
+	if options.MaxBodyBytes == 0 {
+		options.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	for i, name := range options.Headers {
+		options.Headers[i] = http.CanonicalHeaderKey(name)
+	}
+	m := &cache{options: options}
+	return m.handle
+}
+
+func (m *cache) handle(c *engine.Context) {
+	r := c.R
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		c.Next()
+		if status := c.ResponseStatus(); status >= 200 && status < 400 {
+			m.options.Store.Delete(m.key(r))
+		}
+		return
+	}
+	reqCC := parseCacheControl(r.Header.Get("Cache-Control"))
+	if _, ok := reqCC["no-store"]; ok {
+		c.Next()
+		return
+	}
+	key := m.key(r)
+	_, revalidating := r.Context().Value(revalidateKey{}).(bool)
+	if !revalidating && !noCache(reqCC, r.Header) {
+		if entry, ok := m.lookup(key, r); ok {
+			now := time.Now()
+			if now.Before(entry.FreshUntil) {
+				serve(c, entry, "HIT")
+				c.Abort()
+				return
+			}
+			if e := c.Engine(); e != nil {
+				serve(c, entry, "STALE")
+				c.Abort()
+				m.revalidate(e, r, key)
+				return
+			}
+		}
+	}
+
+	w := c.W
+	// 外层中间件已经设置的响应头属于当前请求，比如 X-Request-ID，不保存到缓存
+	outer := make(map[string]bool, len(w.Header()))
+	for k := range w.Header() {
+		outer[k] = true
+	}
+	rec := &recorder{ResponseWriter: w}
+	c.W = rec
+	// 处理链 panic 时也要恢复，外层中间件写响应时不能写到 recorder
+	defer func() {
+		c.W = w
+	}()
+	c.Next()
+	// 流式响应已经直接写出，不缓存
+	if rec.streaming {
+		return
+	}
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	header := w.Header()
+	if rec.status == http.StatusOK && header.Get("ETag") == "" {
+		header.Set("ETag", etag(rec.body.Bytes()))
+	}
+	// HEAD 响应没有响应体，不缓存
+	if r.Method == http.MethodGet {
+		m.save(key, r, rec.status, header, outer, rec.body.Bytes())
+	}
+	header.Set(HeaderName, "MISS")
+	write(w, r, rec.status, rec.body.Bytes())
+}
+
+// key 缓存 key，HEAD 和 GET 使用同一个 key，查询参数排序后参与计算
+// 包含 Host，按 Host 路由的不同站点不会共用缓存
+func (m *cache) key(r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(r.Host)
+	b.WriteString(r.URL.Path)
+	b.WriteByte('?')
+	b.WriteString(r.URL.Query().Encode())
+	for _, name := range m.options.Headers {
+		b.WriteByte('\n')
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+// variantKey 按 Vary 请求头区分的缓存 key
+func variantKey(key string, vary []string, r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(key)
+	b.WriteString("\nvary")
+	for _, name := range vary {
+		b.WriteByte('\n')
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+func (m *cache) lookup(key string, r *http.Request) (*Entry, bool) {
+	entry, ok := m.options.Store.Get(key)
+	if !ok {
+		return nil, false
+	}
+	if len(entry.Vary) > 0 {
+		return m.options.Store.Get(variantKey(key, entry.Vary, r))
+	}
+	return entry, true
+}
+
+// save 保存响应，outer 是外层中间件设置的响应头，不保存
+func (m *cache) save(key string, r *http.Request, status int, header http.Header, outer map[string]bool, body []byte) {
+	if !cacheableStatus[status] || len(body) > m.options.MaxBodyBytes {
+		return
+	}
+	fresh, stale, ok := m.freshness(r, status, header)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	entry := &Entry{
+		Status:     status,
+		Header:     cachedHeader(header, outer),
+		Body:       append([]byte(nil), body...),
+		Created:    now,
+		FreshUntil: now.Add(fresh),
+		StaleUntil: now.Add(fresh + stale),
+	}
+	vary := varyHeaders(header)
+	if len(vary) == 0 {
+		m.options.Store.Set(key, entry)
+		return
+	}
+	m.options.Store.Set(key, &Entry{Vary: vary, Created: now, FreshUntil: entry.FreshUntil, StaleUntil: entry.StaleUntil})
+	m.options.Store.Set(variantKey(key, vary, r), entry)
+}
+
+// freshness 根据响应头计算新鲜时间和 stale-while-revalidate 时间，不能缓存时返回 false
+func (m *cache) freshness(r *http.Request, status int, header http.Header) (fresh time.Duration, stale time.Duration, ok bool) {
+	cc := parseCacheControl(header.Get("Cache-Control"))
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		if _, found := cc[directive]; found {
+			return 0, 0, false
+		}
+	}
+	if header.Get("Set-Cookie") != "" || header.Get("Vary") == "*" {
+		return 0, 0, false
+	}
+	// 共享缓存不能缓存带认证信息的请求，除非响应明确允许
+	_, public := cc["public"]
+	_, sMaxAge := cc["s-maxage"]
+	if r.Header.Get("Authorization") != "" && !public && !sMaxAge {
+		return 0, 0, false
+	}
+
+	if v, found := cc["s-maxage"]; found {
+		fresh, ok = parseSeconds(v)
+	} else if v, found := cc["max-age"]; found {
+		fresh, ok = parseSeconds(v)
+	} else if v := header.Get("Expires"); v != "" {
+		// 格式错误的 Expires 表示已经过期
+		expires, err := http.ParseTime(v)
+		date, dateErr := http.ParseTime(header.Get("Date"))
+		if dateErr != nil {
+			date = time.Now()
+		}
+		fresh, ok = expires.Sub(date), err == nil
+	} else if status == http.StatusOK && m.options.TTL > 0 {
+		fresh, ok = m.options.TTL, true
+	}
+	if !ok {
+		return 0, 0, false
+	}
+	if v, found := cc["stale-while-revalidate"]; found {
+		stale, _ = parseSeconds(v)
+	}
+	if fresh < 0 {
+		fresh = 0
+	}
+	return fresh, stale, fresh > 0 || stale > 0
+}
+
+// revalidate 在后台重新执行请求更新缓存
+func (m *cache) revalidate(e *engine.Engine, r *http.Request, key string) {
+	if _, loaded := m.revalidating.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+	req := r.Clone(context.WithValue(context.Background(), revalidateKey{}, true))
+	req.Method = http.MethodGet
+	req.Body = http.NoBody
+	for _, name := range []string{"If-None-Match", "If-Modified-Since", "Cache-Control", "Pragma"} {
+		req.Header.Del(name)
+	}
+	go func() {
+		defer m.revalidating.Delete(key)
+		e.ServeHTTP(&discardWriter{header: make(http.Header)}, req)
+	}()
+}
+
+// perRequestHeaders 每个请求各自生成的响应头，不保存也不从缓存返回
+var perRequestHeaders = map[string]bool{
+	"Set-Cookie":   true,
+	"Date":         true,
+	"X-Request-Id": true,
+	"Traceparent":  true,
+	"Tracestate":   true,
+}
+
+// cachedHeader 返回要保存的响应头，去掉外层中间件设置的和每个请求各自生成的响应头
+func cachedHeader(header http.Header, outer map[string]bool) http.Header {
+	cached := make(http.Header, len(header))
+	for k, v := range header {
+		if outer[k] || perRequestHeaders[http.CanonicalHeaderKey(k)] {
+			continue
+		}
+		cached[k] = append([]string(nil), v...)
+	}
+	return cached
+}
+
+// serve 返回缓存的响应，当前请求外层中间件已经设置的响应头保留当前请求的值
+func serve(c *engine.Context, entry *Entry, state string) {
+	header := c.W.Header()
+	for k, v := range entry.Header {
+		if _, ok := header[k]; ok || perRequestHeaders[http.CanonicalHeaderKey(k)] {
+			continue
+		}
+		header[k] = append([]string(nil), v...)
+	}
+	header.Set("Age", strconv.Itoa(int(time.Since(entry.Created).Seconds())))
+	header.Set(HeaderName, state)
+	write(c.W, c.R, entry.Status, entry.Body)
+}
+
+// write 写响应，If-None-Match 匹配时返回 304
+func write(w http.ResponseWriter, r *http.Request, status int, body []byte) {
+	header := w.Header()
+	if status == http.StatusOK && notModified(r, header.Get("ETag")) {
+		for _, name := range []string{"Content-Type", "Content-Length", "Content-Encoding"} {
+			header.Del(name)
+		}
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if status != http.StatusNoContent {
+		header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(body)
+	}
+}
+
+// notModified If-None-Match 是否匹配 etag，使用弱比较
+func notModified(r *http.Request, etag string) bool {
+	inm := r.Header.Get("If-None-Match")
+	if inm == "" || etag == "" {
+		return false
+	}
+	if strings.TrimSpace(inm) == "*" {
+		return true
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(inm, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// etag 根据响应体生成强 ETag
+func etag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// noCache 请求要求不使用缓存的响应
+func noCache(cc map[string]string, header http.Header) bool {
+	if _, ok := cc["no-cache"]; ok {
+		return true
+	}
+	if v, ok := cc["max-age"]; ok && v == "0" {
+		return true
+	}
+	return header.Get("Pragma") == "no-cache" && header.Get("Cache-Control") == ""
+}
+
+// parseCacheControl 解析 Cache-Control，指令名转成小写
+func parseCacheControl(value string) map[string]string {
+	cc := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, arg, _ := strings.Cut(part, "=")
+		cc[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(arg), `"`)
+	}
+	return cc
+}
+
+func parseSeconds(value string) (time.Duration, bool) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// varyHeaders 返回排序后的 Vary 请求头
+func varyHeaders(header http.Header) []string {
+	var vary []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				vary = append(vary, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(vary)
+	return vary
+}
+
+// recorder 缓冲响应，处理链执行完后决定是否缓存
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+	// streaming 处理函数调用了 Flush，之后直接写到下层 ResponseWriter
+	streaming bool
+}
+
+func (r *recorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+}
+
+func (r *recorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	if r.streaming {
+		return r.ResponseWriter.Write(data)
+	}
+	return r.body.Write(data)
+}
+
+// Flush 第一次调用时写出已经缓冲的响应，之后的响应不再缓冲，比如 SSE
+func (r *recorder) Flush() {
+	if !r.streaming {
+		r.streaming = true
+		if r.status == 0 {
+			r.status = http.StatusOK
+		}
+		r.ResponseWriter.Header().Set(HeaderName, "MISS")
+		r.ResponseWriter.WriteHeader(r.status)
+		if r.body.Len() > 0 {
+			_, _ = r.ResponseWriter.Write(r.body.Bytes())
+			r.body.Reset()
+		}
+	}
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// discardWriter 后台更新缓存时丢弃响应
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (w *discardWriter) WriteHeader(int) {}
@@ -0,0 +1,288 @@
+package cache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+	"github.com/2456868764/go-learning/web/pkg/middleware/requestid"
+)
+
+func request(e *engine.Engine, method string, path string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+// newEngine 返回一个带缓存的 Engine，处理函数返回调用次数
+func newEngine(options Options, cacheControl string) (*engine.Engine, *atomic.Int32) {
+	var calls atomic.Int32
+	e := engine.New()
+	e.Use(New(options))
+	handler := func(c *engine.Context) {
+		n := calls.Add(1)
+		if cacheControl != "" {
+			c.SetHeader("Cache-Control", cacheControl)
+		}
+		c.StringOk(fmt.Sprintf("%s %d", c.Query("name"), n))
+	}
+	e.GET("/data", handler)
+	e.POST("/data", handler)
+	return e, &calls
+}
+
+func TestCache_HitAndMiss(t *testing.T) {
+	e, calls := newEngine(Options{}, "max-age=60")
+
+	w := request(e, http.MethodGet, "/data?name=a&x=1", nil)
+	assert.Equal(t, "MISS", w.Header().Get(HeaderName))
+	assert.Equal(t, "a 1", w.Body.String())
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	// 查询参数顺序不影响缓存 key
+	w = request(e, http.MethodGet, "/data?x=1&name=a", nil)
+	assert.Equal(t, "HIT", w.Header().Get(HeaderName))
+	assert.Equal(t, "a 1", w.Body.String())
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+	assert.Equal(t, "0", w.Header().Get("Age"))
+
+	// HEAD 使用 GET 的缓存
+	w = request(e, http.MethodHead, "/data?name=a&x=1", nil)
+	assert.Equal(t, "HIT", w.Header().Get(HeaderName))
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, "3", w.Header().Get("Content-Length"))
+
+	// 不同查询参数不命中
+	assert.Equal(t, "b 2", request(e, http.MethodGet, "/data?name=b", nil).Body.String())
+
+	// 请求 no-cache 不读缓存，但是更新缓存
+	w = request(e, http.MethodGet, "/data?name=a&x=1", map[string]string{"Cache-Control": "no-cache"})
+	assert.Equal(t, "a 3", w.Body.String())
+	assert.Equal(t, "a 3", request(e, http.MethodGet, "/data?name=a&x=1", nil).Body.String())
+
+	// 请求 no-store 不读也不写
+	w = request(e, http.MethodGet, "/data?name=c", map[string]string{"Cache-Control": "no-store"})
+	assert.Equal(t, "c 4", w.Body.String())
+	assert.Equal(t, "c 5", request(e, http.MethodGet, "/data?name=c", nil).Body.String())
+
+	// POST 成功后删除缓存
+	request(e, http.MethodPost, "/data?name=c", nil)
+	assert.Equal(t, "c 7", request(e, http.MethodGet, "/data?name=c", nil).Body.String())
+	assert.Equal(t, int32(7), calls.Load())
+}
+
+func TestCache_ConditionalRequest(t *testing.T) {
+	e, calls := newEngine(Options{}, "")
+
+	// 没有缓存配置也生成 ETag
+	w := request(e, http.MethodGet, "/data", nil)
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	w = request(e, http.MethodGet, "/data", map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int32(2), calls.Load())
+
+	e, _ = newEngine(Options{}, "max-age=60")
+	etag = request(e, http.MethodGet, "/data", nil).Header().Get("ETag")
+	w = request(e, http.MethodGet, "/data", map[string]string{"If-None-Match": `"other", W/` + etag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+	assert.Equal(t, "max-age=60", w.Header().Get("Cache-Control"))
+	assert.Empty(t, w.Header().Get("Content-Type"))
+
+	w = request(e, http.MethodGet, "/data", map[string]string{"If-None-Match": `"other"`})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCache_ResponseDirectives(t *testing.T) {
+	testCases := []struct {
+		name   string
+		header map[string]string
+		ttl    time.Duration
+		cached bool
+	}{
+		{name: "no headers", cached: false},
+		{name: "default ttl", ttl: time.Minute, cached: true},
+		{name: "max-age", header: map[string]string{"Cache-Control": "public, max-age=60"}, cached: true},
+		{name: "s-maxage", header: map[string]string{"Cache-Control": "max-age=0, s-maxage=60"}, cached: true},
+		{name: "no-store", header: map[string]string{"Cache-Control": "no-store"}, ttl: time.Minute, cached: false},
+		{name: "private", header: map[string]string{"Cache-Control": "private, max-age=60"}, cached: false},
+		{name: "max-age=0", header: map[string]string{"Cache-Control": "max-age=0"}, ttl: time.Minute, cached: false},
+		{name: "expires", header: map[string]string{"Expires": time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)}, cached: true},
+		{name: "expired", header: map[string]string{"Expires": "0"}, ttl: time.Minute, cached: false},
+		{name: "set-cookie", header: map[string]string{"Cache-Control": "max-age=60", "Set-Cookie": "a=b"}, cached: false},
+		{name: "vary *", header: map[string]string{"Cache-Control": "max-age=60", "Vary": "*"}, cached: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := engine.New()
+			e.Use(New(Options{TTL: tc.ttl}))
+			var calls int
+			e.GET("/", func(c *engine.Context) {
+				calls++
+				for k, v := range tc.header {
+					c.SetHeader(k, v)
+				}
+				c.StringOk("ok")
+			})
+			request(e, http.MethodGet, "/", nil)
+			w := request(e, http.MethodGet, "/", nil)
+			assert.Equal(t, tc.cached, w.Header().Get(HeaderName) == "HIT")
+			assert.Equal(t, tc.cached, calls == 1)
+		})
+	}
+
+	// 带认证信息的请求只有 public 响应才缓存
+	e, calls := newEngine(Options{}, "max-age=60")
+	auth := map[string]string{"Authorization": "Bearer token"}
+	request(e, http.MethodGet, "/data", auth)
+	assert.Equal(t, "MISS", request(e, http.MethodGet, "/data", auth).Header().Get(HeaderName))
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestCache_VaryAndKeyHeaders(t *testing.T) {
+	e := engine.New()
+	e.Use(New(Options{Headers: []string{"x-tenant"}}))
+	var calls int
+	e.GET("/", func(c *engine.Context) {
+		calls++
+		c.SetHeader("Cache-Control", "max-age=60")
+		c.SetHeader("Vary", "Accept-Language")
+		c.StringOk(fmt.Sprintf("%s %s %d", c.GetHeader("X-Tenant"), c.GetHeader("Accept-Language"), calls))
+	})
+	zh := map[string]string{"Accept-Language": "zh", "X-Tenant": "a"}
+	en := map[string]string{"Accept-Language": "en", "X-Tenant": "a"}
+	assert.Equal(t, "a zh 1", request(e, http.MethodGet, "/", zh).Body.String())
+	assert.Equal(t, "a en 2", request(e, http.MethodGet, "/", en).Body.String())
+	assert.Equal(t, "a zh 1", request(e, http.MethodGet, "/", zh).Body.String())
+	assert.Equal(t, "a en 2", request(e, http.MethodGet, "/", en).Body.String())
+	assert.Equal(t, "b zh 3", request(e, http.MethodGet, "/", map[string]string{"Accept-Language": "zh", "X-Tenant": "b"}).Body.String())
+}
+
+func TestCache_HostAndStreaming(t *testing.T) {
+	e, calls := newEngine(Options{}, "max-age=60")
+	// 不同 Host 不共用缓存
+	req := httptest.NewRequest(http.MethodGet, "http://a.example.com/data?name=a", nil)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, "a 1", w.Body.String())
+	req = httptest.NewRequest(http.MethodGet, "http://b.example.com/data?name=a", nil)
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, "MISS", w.Header().Get(HeaderName))
+	assert.Equal(t, "a 2", w.Body.String())
+	assert.Equal(t, int32(2), calls.Load())
+
+	// SSE 响应边写边 flush，不缓存
+	e.GET("/events", func(c *engine.Context) {
+		c.SetHeader("Cache-Control", "max-age=60")
+		calls.Add(1)
+		for i := 0; i < 2; i++ {
+			assert.Nil(t, c.SSEvent(engine.SSEvent{Data: fmt.Sprint(i)}))
+		}
+	})
+	for i := 0; i < 2; i++ {
+		w = request(e, http.MethodGet, "/events", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, w.Flushed)
+		assert.Equal(t, "MISS", w.Header().Get(HeaderName))
+		assert.Equal(t, "data: 0\n\ndata: 1\n\n", w.Body.String())
+	}
+	assert.Equal(t, int32(4), calls.Load())
+}
+
+func TestCache_PerRequestHeaders(t *testing.T) {
+	e := engine.New()
+	e.Use(requestid.New(requestid.Options{}), New(Options{}))
+	e.GET("/data", func(c *engine.Context) {
+		c.SetHeader("Cache-Control", "max-age=60")
+		c.SetHeader("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		c.StringOk("data")
+	})
+
+	first := request(e, http.MethodGet, "/data", nil)
+	assert.Equal(t, "MISS", first.Header().Get(HeaderName))
+	w := request(e, http.MethodGet, "/data", nil)
+	assert.Equal(t, "HIT", w.Header().Get(HeaderName))
+	// 命中缓存时返回当前请求的请求 ID，不返回第一次请求的请求 ID 和 trace 头
+	assert.NotEmpty(t, w.Header().Get(requestid.HeaderName))
+	assert.NotEqual(t, first.Header().Get(requestid.HeaderName), w.Header().Get(requestid.HeaderName))
+	assert.Empty(t, w.Header().Get("Traceparent"))
+	assert.Equal(t, "data", w.Body.String())
+
+	// 带 Set-Cookie 的响应仍然不缓存
+	e.GET("/login", func(c *engine.Context) {
+		c.SetHeader("Cache-Control", "max-age=60")
+		c.SetCookie("session", "1", 0, "/", "", false, true)
+		c.StringOk("ok")
+	})
+	request(e, http.MethodGet, "/login", nil)
+	assert.Equal(t, "MISS", request(e, http.MethodGet, "/login", nil).Header().Get(HeaderName))
+}
+
+func TestCache_StaleWhileRevalidate(t *testing.T) {
+	var calls atomic.Int32
+	done := make(chan struct{}, 10)
+	e := engine.New()
+	e.Use(New(Options{}))
+	e.GET("/", func(c *engine.Context) {
+		n := calls.Add(1)
+		c.SetHeader("Cache-Control", "max-age=0, stale-while-revalidate=60")
+		c.StringOk(fmt.Sprint(n))
+		done <- struct{}{}
+	})
+	assert.Equal(t, "1", request(e, http.MethodGet, "/", nil).Body.String())
+	<-done
+
+	// 过期后返回旧响应，后台更新
+	w := request(e, http.MethodGet, "/", nil)
+	assert.Equal(t, "STALE", w.Header().Get(HeaderName))
+	assert.Equal(t, "1", w.Body.String())
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("cache not revalidated")
+	}
+	assert.Eventually(t, func() bool {
+		return request(e, http.MethodGet, "/", nil).Body.String() == "2"
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore(2, 0)
+	expires := time.Now().Add(time.Minute)
+	s.Set("a", &Entry{Body: []byte("a"), StaleUntil: expires})
+	s.Set("b", &Entry{Body: []byte("b"), StaleUntil: expires})
+	_, ok := s.Get("a")
+	assert.True(t, ok)
+	// b 最久没有访问，被淘汰
+	s.Set("c", &Entry{Body: []byte("c"), StaleUntil: expires})
+	_, ok = s.Get("b")
+	assert.False(t, ok)
+	assert.Equal(t, 2, s.Len())
+
+	s.Set("expired", &Entry{StaleUntil: time.Now().Add(-time.Second)})
+	_, ok = s.Get("expired")
+	assert.False(t, ok)
+
+	s = NewMemoryStore(0, 10)
+	s.Set("a", &Entry{Body: make([]byte, 6), StaleUntil: expires})
+	s.Set("b", &Entry{Body: make([]byte, 6), StaleUntil: expires})
+	_, ok = s.Get("a")
+	assert.False(t, ok)
+	s.Delete("b")
+	assert.Equal(t, 0, s.Len())
+}
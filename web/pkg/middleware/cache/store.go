@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Entry 缓存的响应
+type Entry struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	// Vary 响应 Vary 头列出的请求头，非空时这是索引项，实际响应按请求头的值另外保存
+	Vary []string
+	// Created 保存时间，用于计算 Age
+	Created time.Time
+	// FreshUntil 之前直接返回缓存
+	FreshUntil time.Time
+	// StaleUntil 之前返回旧响应并在后台更新，之后缓存失效
+	StaleUntil time.Time
+}
+
+// size 估算占用的字节数
+func (e *Entry) size() int {
+	n := len(e.Body)
+	for k, values := range e.Header {
+		n += len(k)
+		for _, v := range values {
+			n += len(v)
+		}
+	}
+	return n
+}
+
+// Store 缓存存储，实现需要支持并发调用
+type Store interface {
+	// Get 返回 key 对应的缓存，不存在或者已经超过 StaleUntil 返回 false
+	Get(key string) (*Entry, bool)
+	Set(key string, entry *Entry)
+	Delete(key string)
+}
+
+// MemoryStore 内存 LRU 存储，超过条数或者字节数限制时淘汰最久没有访问的缓存
+type MemoryStore struct {
+	maxEntries int
+	maxBytes   int
+
+	mu    sync.Mutex
+	bytes int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type memoryItem struct {
+	key   string
+	entry *Entry
+}
+
+// NewMemoryStore 创建内存存储，maxEntries 和 maxBytes 小于等于 0 表示不限制
+func NewMemoryStore(maxEntries int, maxBytes int) *MemoryStore {
+	return &MemoryStore{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (s *MemoryStore) Get(key string) (*Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := elem.Value.(*memoryItem)
+	if !time.Now().Before(item.entry.StaleUntil) {
+		s.remove(elem)
+		return nil, false
+	}
+	s.ll.MoveToFront(elem)
+	return item.entry, true
+}
+
+func (s *MemoryStore) Set(key string, entry *Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.items[key]; ok {
+		s.remove(elem)
+	}
+	s.items[key] = s.ll.PushFront(&memoryItem{key: key, entry: entry})
+	s.bytes += entry.size()
+	for s.ll.Len() > 1 && ((s.maxEntries > 0 && s.ll.Len() > s.maxEntries) || (s.maxBytes > 0 && s.bytes > s.maxBytes)) {
+		s.remove(s.ll.Back())
+	}
+}
+
+func (s *MemoryStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.items[key]; ok {
+		s.remove(elem)
+	}
+}
+
+// Len 返回缓存条数
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ll.Len()
+}
+
+func (s *MemoryStore) remove(elem *list.Element) {
+	item := s.ll.Remove(elem).(*memoryItem)
+	delete(s.items, item.key)
+	s.bytes -= item.entry.size()
+}
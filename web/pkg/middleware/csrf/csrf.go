@@ -0,0 +1,235 @@
+package csrf
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+)
+
+const (
+	// TokenKey 当前请求 CSRF token 保存在 Context.Keys 里的 key，模板可以直接读取
+	TokenKey = "csrf_token"
+	// exemptMetaKey 路由元数据里标记不做 CSRF 校验
+	exemptMetaKey = "csrf.exempt"
+	tokenLength   = 32
+)
+
+var ErrorNoToken = errors.New("csrf: token not found in request")
+var ErrorBadToken = errors.New("csrf: token mismatch")
+var ErrorBadOrigin = errors.New("csrf: origin does not match")
+var ErrorBadCookie = errors.New("csrf: invalid token cookie")
+
+// Options CSRF 配置
+type Options struct {
+	// Key 签名 cookie 的密钥，必须配置
+	Key []byte
+	// CookieName 保存 token 的 cookie 名字，默认 _csrf
+	CookieName string
+	// HeaderName 从这个请求头读取 token，默认 X-CSRF-Token
+	HeaderName string
+	// FieldName 从这个表单字段读取 token，默认 csrf_token
+	FieldName string
+	// Cookie 配置
+	Path     string
+	Domain   string
+	MaxAge   int
+	Secure   bool
+	SameSite http.SameSite
+	// TrustedOrigins 除了同源之外允许的来源，格式 scheme://host[:port]
+	TrustedOrigins []string
+	// ErrorHandler 校验失败时调用，默认返回 403
+	ErrorHandler func(c *engine.Context, err error)
+}
+
+// Exempt 路由不做 CSRF 校验，比如给第三方回调的接口
+func Exempt() engine.RouteOption {
+	return engine.WithMeta(exemptMetaKey, true)
+}
+
+// Token 返回当前请求的 CSRF token，用于渲染到表单隐藏字段或者页面 meta 里
+func Token(c *engine.Context) string {
+	return c.GetString(TokenKey)
+}
+
+// Protect CSRF 中间件，使用 double-submit cookie 方式
+// 安全方法请求签发 token 写入签名 cookie，非安全方法请求校验来源，并要求请求头或者表单里的 token 和 cookie 一致
+func Protect(options Options) engine.HandlerFunc {
+	if len(options.Key) == 0 {
+		panic("csrf: Options.Key is required")
+	}
+	if options.CookieName == "" {
+		options.CookieName = "_csrf"
+	}
+	if options.HeaderName == "" {
+		options.HeaderName = "X-CSRF-Token"
+	}
+	if options.FieldName == "" {
+		options.FieldName = TokenKey
+	}
+	if options.Path == "" {
+		options.Path = "/"
+	}
+	if options.SameSite == 0 {
+		options.SameSite = http.SameSiteLaxMode
+	}
+	if options.ErrorHandler == nil {
+		options.ErrorHandler = func(c *engine.Context, err error) {
+			c.StringFormat(http.StatusForbidden, "Forbidden: %v", err)
+		}
+	}
+	trusted := make(map[string]bool, len(options.TrustedOrigins))
+	for _, origin := range options.TrustedOrigins {
+		trusted[strings.ToLower(origin)] = true
+	}
+	p := &protector{options: options, trusted: trusted}
+	return p.handle
+}
+
+type protector struct {
+	options Options
+	trusted map[string]bool
+}
+
+func (p *protector) handle(c *engine.Context) {
+	if exempt, _ := c.RouteMeta(exemptMetaKey); exempt == true {
+		return
+	}
+
+	token, err := p.cookieToken(c)
+	if err != nil {
+		// 没有 cookie 或者 cookie 无效，重新签发
+		token, err = newToken()
+		if err != nil {
+			c.StringFormat(http.StatusInternalServerError, "Internal Server Error")
+			c.Abort()
+			return
+		}
+		p.setCookie(c, token)
+	}
+	c.Set(TokenKey, base64.RawURLEncoding.EncodeToString(token))
+	// 缓存相关：响应内容依赖 cookie
+	c.W.Header().Add("Vary", "Cookie")
+
+	if isSafeMethod(c.Method) {
+		return
+	}
+
+	if err = p.checkOrigin(c); err != nil {
+		p.fail(c, err)
+		return
+	}
+	requestToken := c.GetHeader(p.options.HeaderName)
+	if requestToken == "" {
+		requestToken = c.PostForm(p.options.FieldName)
+	}
+	if requestToken == "" {
+		p.fail(c, ErrorNoToken)
+		return
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(requestToken)
+	if err != nil || subtle.ConstantTimeCompare(decoded, token) != 1 {
+		p.fail(c, ErrorBadToken)
+		return
+	}
+}
+
+func (p *protector) fail(c *engine.Context, err error) {
+	p.options.ErrorHandler(c, err)
+	c.Abort()
+}
+
+// checkOrigin 校验 Origin，没有 Origin 时校验 Referer，都没有时 https 请求拒绝，http 请求放行
+func (p *protector) checkOrigin(c *engine.Context) error {
+	source := c.GetHeader("Origin")
+	if source == "" || source == "null" {
+		source = c.GetHeader("Referer")
+	}
+	if source == "" {
+		if c.R.TLS != nil {
+			return ErrorBadOrigin
+		}
+		return nil
+	}
+	u, err := url.Parse(source)
+	if err != nil || u.Host == "" {
+		return ErrorBadOrigin
+	}
+	origin := strings.ToLower(u.Scheme + "://" + u.Host)
+	if p.trusted[origin] {
+		return nil
+	}
+	scheme := "http"
+	if c.R.TLS != nil {
+		scheme = "https"
+	}
+	if origin != strings.ToLower(scheme+"://"+c.R.Host) {
+		return ErrorBadOrigin
+	}
+	return nil
+}
+
+// cookieToken 读取并校验 cookie 里的 token，cookie 格式 base64(token).base64(签名)
+func (p *protector) cookieToken(c *engine.Context) ([]byte, error) {
+	cookie, err := c.R.Cookie(p.options.CookieName)
+	if err != nil {
+		return nil, err
+	}
+	value, signature, found := strings.Cut(cookie.Value, ".")
+	if !found {
+		return nil, ErrorBadCookie
+	}
+	token, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(token) != tokenLength {
+		return nil, ErrorBadCookie
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, p.sign(token)) {
+		return nil, ErrorBadCookie
+	}
+	return token, nil
+}
+
+func (p *protector) setCookie(c *engine.Context, token []byte) {
+	value := base64.RawURLEncoding.EncodeToString(token) + "." + base64.RawURLEncoding.EncodeToString(p.sign(token))
+	http.SetCookie(c.W, &http.Cookie{
+		Name:     p.options.CookieName,
+		Value:    value,
+		Path:     p.options.Path,
+		Domain:   p.options.Domain,
+		MaxAge:   p.options.MaxAge,
+		Secure:   p.options.Secure,
+		HttpOnly: true,
+		SameSite: p.options.SameSite,
+	})
+}
+
+func (p *protector) sign(token []byte) []byte {
+	h := hmac.New(sha256.New, p.options.Key)
+	h.Write(token)
+	return h.Sum(nil)
+}
+
+func newToken() ([]byte, error) {
+	token := make([]byte, tokenLength)
+	if _, err := io.ReadFull(rand.Reader, token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
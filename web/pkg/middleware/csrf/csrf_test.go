@@ -0,0 +1,94 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCSRFEngine() *engine.Engine {
+	e := engine.New()
+	e.Use(Protect(Options{Key: []byte("csrf-secret"), TrustedOrigins: []string{"https://admin.example.com"}}))
+	e.GET("/form", func(c *engine.Context) {
+		c.StringOk(Token(c))
+	})
+	e.POST("/form", func(c *engine.Context) {
+		c.StringOk("ok")
+	})
+	e.POST("/webhook", func(c *engine.Context) {
+		c.StringOk("ok")
+	}, Exempt())
+	return e
+}
+
+func serve(e *engine.Engine, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestProtect(t *testing.T) {
+	e := newCSRFEngine()
+
+	w := serve(e, httptest.NewRequest(http.MethodGet, "http://example.com/form", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	token := w.Body.String()
+	assert.NotEmpty(t, token)
+	cookies := w.Result().Cookies()
+	assert.Equal(t, 1, len(cookies))
+
+	newPost := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/form", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		return req
+	}
+
+	// 表单字段提交 token
+	req := newPost(url.Values{TokenKey: {token}}.Encode())
+	req.Header.Set("Origin", "http://example.com")
+	assert.Equal(t, http.StatusOK, serve(e, req).Code)
+
+	// 请求头提交 token
+	req = newPost("")
+	req.Header.Set("X-CSRF-Token", token)
+	req.Header.Set("Referer", "http://example.com/form")
+	assert.Equal(t, http.StatusOK, serve(e, req).Code)
+
+	// 信任的来源
+	req = newPost("")
+	req.Header.Set("X-CSRF-Token", token)
+	req.Header.Set("Origin", "https://admin.example.com")
+	assert.Equal(t, http.StatusOK, serve(e, req).Code)
+
+	// 没有 token
+	req = newPost("")
+	assert.Equal(t, http.StatusForbidden, serve(e, req).Code)
+
+	// token 不匹配
+	req = newPost("")
+	req.Header.Set("X-CSRF-Token", "invalid")
+	assert.Equal(t, http.StatusForbidden, serve(e, req).Code)
+
+	// 跨站来源
+	req = newPost("")
+	req.Header.Set("X-CSRF-Token", token)
+	req.Header.Set("Origin", "http://evil.com")
+	assert.Equal(t, http.StatusForbidden, serve(e, req).Code)
+
+	// 没有 cookie
+	req = httptest.NewRequest(http.MethodPost, "http://example.com/form", nil)
+	req.Header.Set("X-CSRF-Token", token)
+	assert.Equal(t, http.StatusForbidden, serve(e, req).Code)
+
+	// 豁免路由
+	req = httptest.NewRequest(http.MethodPost, "http://example.com/webhook", nil)
+	assert.Equal(t, http.StatusOK, serve(e, req).Code)
+}
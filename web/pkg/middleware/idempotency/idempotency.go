@@ -0,0 +1,254 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+)
+
+const (
+	// HeaderName 客户端传入幂等 key 的请求头
+	HeaderName = "Idempotency-Key"
+	// ReplayedHeader 重放的响应带上这个响应头
+	ReplayedHeader = "Idempotent-Replayed"
+	// maxKeyLength 幂等 key 最大长度
+	maxKeyLength = 255
+)
+
+// Options 幂等中间件配置
+type Options struct {
+	// Store 记录存储，默认 MemoryStore
+	Store Store
+	// TTL 处理完成的记录保存时间，默认 24h
+	TTL time.Duration
+	// LockTTL 处理中记录的保存时间，进程异常退出时到期后可以重试，默认 1m
+	LockTTL time.Duration
+	// Wait 重复请求到达时第一个请求还在处理，最多等待多久，超时返回 409，默认 0 表示直接返回 409
+	Wait time.Duration
+	// Methods 需要幂等处理的方法，默认 POST 和 PATCH
+	Methods []string
+	// Required 为 true 时没有 Idempotency-Key 返回 400，否则直接处理
+	Required bool
+	// Scope 返回 key 的作用域，比如当前用户 ID，不同作用域的相同 key 互不影响
+	Scope func(c *engine.Context) string
+}
+
+// pollInterval 等待第一个请求完成时查询记录的间隔
+const pollInterval = 10 * time.Millisecond
+
+// New 幂等中间件
+// 第一个请求的响应（状态码、响应头和响应体）保存下来，相同 key 的重复请求直接重放
+// 同一个 key 的请求方法、路径、查询参数或者请求体不同时返回 422，第一个请求还在处理时返回 409
+// 5xx 响应和 panic 不保存，客户端可以使用同一个 key 重试
+func New(options Options) engine.HandlerFunc {
+	if options.Store == nil {
+		options.Store = NewMemoryStore()
+	}
+	if options.TTL == 0 {
+		options.TTL = 24 * time.Hour
+	}
+	if options.LockTTL == 0 {
+		options.LockTTL = time.Minute
+	}
+	if len(options.Methods) == 0 {
+		options.Methods = []string{http.MethodPost, http.MethodPatch}
+	}
+	return func(c *engine.Context) {
+		if !contains(options.Methods, c.R.Method) {
+			c.Next()
+			return
+		}
+		key := c.GetHeader(HeaderName)
+		if key == "" {
+			if options.Required {
+				c.StringFormat(http.StatusBadRequest, "Bad Request: missing %s header", HeaderName)
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+		if len(key) > maxKeyLength {
+			c.StringFormat(http.StatusBadRequest, "Bad Request: %s too long", HeaderName)
+			c.Abort()
+			return
+		}
+		if options.Scope != nil {
+			key = scopedKey(options.Scope(c), key)
+		}
+
+		fingerprint, err := requestFingerprint(c)
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				c.StringFormat(http.StatusRequestEntityTooLarge, "Request Entity Too Large")
+			} else {
+				c.StringFormat(http.StatusBadRequest, "Bad Request: read body failed")
+			}
+			c.Abort()
+			return
+		}
+
+		record, locked, err := lock(options, key, fingerprint)
+		if err != nil {
+			c.StringFormat(http.StatusInternalServerError, "Internal Server Error")
+			c.Abort()
+			return
+		}
+		if !locked {
+			switch {
+			case record.Fingerprint != fingerprint:
+				c.StringFormat(http.StatusUnprocessableEntity, "Unprocessable Entity: %s reused with a different request", HeaderName)
+			case !record.Done:
+				c.SetHeader("Retry-After", "1")
+				c.StringFormat(http.StatusConflict, "Conflict: request with the same %s is in progress", HeaderName)
+			default:
+				replay(c, record)
+			}
+			c.Abort()
+			return
+		}
+
+		rec := &recorder{ResponseWriter: c.W}
+		c.W = rec
+		saved := false
+		defer func() {
+			c.W = rec.ResponseWriter
+			// 没有保存时删除处理中的记录，包括 panic
+			if !saved {
+				_ = options.Store.Delete(key)
+			}
+		}()
+		c.Next()
+		status := rec.status
+		if status == 0 {
+			status = c.ResponseStatus()
+		}
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		header := rec.header
+		if header == nil {
+			header = rec.Header().Clone()
+		}
+		saved = options.Store.Save(key, &Record{
+			Fingerprint: fingerprint,
+			Done:        true,
+			Status:      status,
+			Header:      header,
+			Body:        rec.body.Bytes(),
+		}, options.TTL) == nil
+	}
+}
+
+// lock 抢占 key，第一个请求还在处理并且设置了 Wait 时等待它完成
+func lock(options Options, key string, fingerprint string) (*Record, bool, error) {
+	deadline := time.Now().Add(options.Wait)
+	for {
+		record, locked, err := options.Store.Lock(key, &Record{Fingerprint: fingerprint}, options.LockTTL)
+		if err != nil || locked || record.Done || record.Fingerprint != fingerprint || !time.Now().Before(deadline) {
+			return record, locked, err
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// requestFingerprint 计算方法、路径、查询参数和请求体的摘要，读取后恢复请求体
+func requestFingerprint(c *engine.Context) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(c.R.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(c.R.URL.Path))
+	h.Write([]byte{0})
+	h.Write([]byte(c.R.URL.Query().Encode()))
+	h.Write([]byte{0})
+	if c.R.Body != nil && c.R.Body != http.NoBody {
+		body, err := io.ReadAll(c.R.Body)
+		if err != nil {
+			return "", err
+		}
+		_ = c.R.Body.Close()
+		c.R.Body = io.NopCloser(bytes.NewReader(body))
+		h.Write(body)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// scopedKey 作用域加长度前缀，作用域和 key 里的 : 不会拼出相同的存储 key
+func scopedKey(scope string, key string) string {
+	return strconv.Itoa(len(scope)) + ":" + scope + ":" + key
+}
+
+// replaySkipHeaders 每个请求各自生成的响应头，不从第一次的响应重放
+var replaySkipHeaders = map[string]bool{
+	"Set-Cookie":   true,
+	"Date":         true,
+	"X-Request-Id": true,
+	"Traceparent":  true,
+	"Tracestate":   true,
+}
+
+// replay 重放保存的响应，当前请求外层中间件已经设置的响应头保留当前请求的值
+func replay(c *engine.Context, record *Record) {
+	header := c.W.Header()
+	for k, v := range record.Header {
+		if _, ok := header[k]; ok || replaySkipHeaders[http.CanonicalHeaderKey(k)] {
+			continue
+		}
+		header[k] = append([]string(nil), v...)
+	}
+	header.Set(ReplayedHeader, "true")
+	header.Set("Content-Length", strconv.Itoa(len(record.Body)))
+	c.W.WriteHeader(record.Status)
+	_, _ = c.W.Write(record.Body)
+}
+
+// recorder 写响应的同时记录状态码、响应头和响应体
+type recorder struct {
+	http.ResponseWriter
+	status int
+	// 写入状态码时的响应头
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+		r.header = r.Header().Clone()
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *recorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *recorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
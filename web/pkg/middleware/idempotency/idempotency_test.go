@@ -0,0 +1,220 @@
+package idempotency
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+)
+
+func post(e *engine.Engine, path string, key string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(HeaderName, key)
+	}
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+// newEngine 返回创建订单的 Engine，订单号递增
+func newEngine(options Options) (*engine.Engine, *atomic.Int32) {
+	var orders atomic.Int32
+	e := engine.New()
+	e.Use(New(options))
+	e.POST("/orders", func(c *engine.Context) {
+		body, _ := io.ReadAll(c.R.Body)
+		id := orders.Add(1)
+		c.SetHeader("Location", fmt.Sprintf("/orders/%d", id))
+		c.StringFormat(http.StatusCreated, "order %d %s", id, body)
+	})
+	e.POST("/fail", func(c *engine.Context) {
+		orders.Add(1)
+		c.StringFormat(http.StatusInternalServerError, "failed")
+	})
+	return e, &orders
+}
+
+func TestIdempotency_Replay(t *testing.T) {
+	e, orders := newEngine(Options{})
+
+	w := post(e, "/orders", "key-1", `{"item":1}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, `order 1 {"item":1}`, w.Body.String())
+	assert.Empty(t, w.Header().Get(ReplayedHeader))
+
+	// 重复请求重放第一次的响应，不再创建订单
+	w = post(e, "/orders", "key-1", `{"item":1}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, `order 1 {"item":1}`, w.Body.String())
+	assert.Equal(t, "/orders/1", w.Header().Get("Location"))
+	assert.Equal(t, "true", w.Header().Get(ReplayedHeader))
+	assert.Equal(t, int32(1), orders.Load())
+
+	// 同一个 key 不同请求体
+	w = post(e, "/orders", "key-1", `{"item":2}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	// 同一个 key 不同路径
+	assert.Equal(t, http.StatusUnprocessableEntity, post(e, "/orders?dry=1", "key-1", `{"item":1}`).Code)
+
+	// 不同 key 和没有 key 正常处理
+	assert.Equal(t, `order 2 {"item":1}`, post(e, "/orders", "key-2", `{"item":1}`).Body.String())
+	assert.Equal(t, `order 3 {"item":1}`, post(e, "/orders", "", `{"item":1}`).Body.String())
+	assert.Equal(t, `order 4 {"item":1}`, post(e, "/orders", "", `{"item":1}`).Body.String())
+
+	// 5xx 不保存，可以重试
+	assert.Equal(t, http.StatusInternalServerError, post(e, "/fail", "key-3", "").Code)
+	assert.Equal(t, http.StatusInternalServerError, post(e, "/fail", "key-3", "").Code)
+	assert.Equal(t, int32(6), orders.Load())
+}
+
+func TestIdempotency_Options(t *testing.T) {
+	e, _ := newEngine(Options{Required: true})
+	assert.Equal(t, http.StatusBadRequest, post(e, "/orders", "", "{}").Code)
+	assert.Equal(t, http.StatusBadRequest, post(e, "/orders", strings.Repeat("k", 256), "{}").Code)
+
+	// 不同作用域的相同 key 互不影响
+	e, orders := newEngine(Options{Scope: func(c *engine.Context) string {
+		return c.GetHeader("X-User")
+	}})
+	for _, user := range []string{"a", "b", "a"} {
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader("{}"))
+		req.Header.Set(HeaderName, "key")
+		req.Header.Set("X-User", user)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	assert.Equal(t, int32(2), orders.Load())
+
+	// 作用域里的 : 不会和 key 拼出相同的存储 key
+	for _, item := range [][2]string{{"a:b", "c"}, {"a", "b:c"}} {
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader("{}"))
+		req.Header.Set(HeaderName, item[1])
+		req.Header.Set("X-User", item[0])
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	assert.Equal(t, int32(4), orders.Load())
+}
+
+func TestIdempotency_ReplayHeaders(t *testing.T) {
+	var requests atomic.Int32
+	e := engine.New()
+	e.Use(func(c *engine.Context) {
+		c.SetHeader("X-Request-ID", fmt.Sprintf("req-%d", requests.Add(1)))
+		c.Next()
+	})
+	e.Use(New(Options{}))
+	e.POST("/login", func(c *engine.Context) {
+		c.SetCookie("session", "first", 0, "/", "", false, true)
+		c.SetHeader("Location", "/me")
+		c.StringFormat(http.StatusCreated, "ok")
+	})
+
+	first := post(e, "/login", "key", "")
+	assert.Equal(t, "req-1", first.Header().Get("X-Request-ID"))
+	assert.NotEmpty(t, first.Header().Get("Set-Cookie"))
+
+	// 重放保留当前请求的 X-Request-ID，不重放 Set-Cookie
+	w := post(e, "/login", "key", "")
+	assert.Equal(t, "true", w.Header().Get(ReplayedHeader))
+	assert.Equal(t, "req-2", w.Header().Get("X-Request-ID"))
+	assert.Empty(t, w.Header().Get("Set-Cookie"))
+	assert.Equal(t, "/me", w.Header().Get("Location"))
+	assert.Equal(t, "ok", w.Body.String())
+}
+
+func TestIdempotency_ConcurrentDuplicates(t *testing.T) {
+	release := make(chan struct{})
+	var orders atomic.Int32
+	e := engine.New()
+	e.Use(New(Options{}))
+	e.POST("/orders", func(c *engine.Context) {
+		<-release
+		c.StringFormat(http.StatusCreated, "order %d", orders.Add(1))
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var first *httptest.ResponseRecorder
+	go func() {
+		defer wg.Done()
+		first = post(e, "/orders", "key", "{}")
+	}()
+	// 第一个请求处理中，重复请求返回 409
+	assert.Eventually(t, func() bool {
+		return post(e, "/orders", "key", "{}").Code == http.StatusConflict
+	}, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, "order 1", first.Body.String())
+	assert.Equal(t, "order 1", post(e, "/orders", "key", "{}").Body.String())
+
+	// 设置 Wait 时等待第一个请求完成后重放
+	release = make(chan struct{})
+	e = engine.New()
+	e.Use(New(Options{Wait: time.Second}))
+	e.POST("/orders", func(c *engine.Context) {
+		<-release
+		c.StringFormat(http.StatusCreated, "order %d", orders.Add(1))
+	})
+	results := make([]*httptest.ResponseRecorder, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = post(e, "/orders", "key", "{}")
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	for _, w := range results {
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "order 2", w.Body.String())
+	}
+}
+
+func TestIdempotency_Panic(t *testing.T) {
+	store := NewMemoryStore()
+	e := engine.New()
+	e.Use(New(Options{Store: store}))
+	e.POST("/panic", func(c *engine.Context) {
+		panic("boom")
+	})
+	assert.Panics(t, func() {
+		post(e, "/panic", "key", "")
+	})
+	// panic 后删除处理中的记录，可以重试
+	_, locked, err := store.Lock("key", &Record{}, time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, locked)
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	record, locked, err := s.Lock("a", &Record{Fingerprint: "f"}, time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, locked)
+	assert.False(t, record.Done)
+
+	record, locked, _ = s.Lock("a", &Record{Fingerprint: "g"}, time.Minute)
+	assert.False(t, locked)
+	assert.Equal(t, "f", record.Fingerprint)
+
+	assert.Nil(t, s.Save("a", &Record{Fingerprint: "f", Done: true}, time.Minute))
+	record, _, _ = s.Lock("a", &Record{}, time.Minute)
+	assert.True(t, record.Done)
+
+	// 过期后可以重新占用
+	_, _, _ = s.Lock("b", &Record{}, -time.Second)
+	_, locked, _ = s.Lock("b", &Record{}, time.Minute)
+	assert.True(t, locked)
+}
@@ -0,0 +1,87 @@
+package idempotency
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Record 一个幂等 key 的处理记录
+type Record struct {
+	// Fingerprint 请求指纹，同一个 key 请求不同时拒绝
+	Fingerprint string `json:"fingerprint"`
+	// Done false 表示第一个请求还在处理
+	Done   bool        `json:"done"`
+	Status int         `json:"status,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+// Store 幂等记录存储，实现需要保证 Lock 的原子性，多实例部署时使用共享存储，比如 Redis SET NX
+type Store interface {
+	// Lock key 不存在时保存处理中的记录并返回 true，存在时返回已有记录和 false
+	Lock(key string, record *Record, ttl time.Duration) (*Record, bool, error)
+	// Save 保存处理完成的记录，覆盖处理中的记录
+	Save(key string, record *Record, ttl time.Duration) error
+	// Delete 删除记录，处理失败时调用，客户端可以使用同一个 key 重试
+	Delete(key string) error
+}
+
+// MemoryStore 内存存储，只适合单实例部署
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]memoryRecord
+	// 上次清理过期记录的时间
+	lastGC time.Time
+}
+
+type memoryRecord struct {
+	record  *Record
+	expires time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records: make(map[string]memoryRecord),
+		lastGC:  time.Now(),
+	}
+}
+
+func (s *MemoryStore) Lock(key string, record *Record, ttl time.Duration) (*Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.gc(now)
+	if existing, ok := s.records[key]; ok && now.Before(existing.expires) {
+		return existing.record, false, nil
+	}
+	s.records[key] = memoryRecord{record: record, expires: now.Add(ttl)}
+	return record, true, nil
+}
+
+func (s *MemoryStore) Save(key string, record *Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = memoryRecord{record: record, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// gc 每分钟最多清理一次过期记录
+func (s *MemoryStore) gc(now time.Time) {
+	if now.Sub(s.lastGC) < time.Minute {
+		return
+	}
+	s.lastGC = now
+	for key, r := range s.records {
+		if !now.Before(r.expires) {
+			delete(s.records, key)
+		}
+	}
+}
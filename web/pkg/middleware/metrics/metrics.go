@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+)
+
+// unmatchedRoute 没有命中路由的请求使用的 route 标签，避免原始路径导致标签数量爆炸
+const unmatchedRoute = "unmatched"
+
+// otherMethod 非标准方法使用的 method 标签，客户端可以发送任意方法，不能直接作为标签
+const otherMethod = "OTHER"
+
+var knownMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// methodLabel 返回 method 标签，非标准方法统一为 OTHER
+func methodLabel(method string) string {
+	if knownMethods[method] {
+		return method
+	}
+	return otherMethod
+}
+
+// Options 指标配置
+type Options struct {
+	// Namespace 指标名前缀，默认 http
+	Namespace string
+	// Path 暴露指标的路由，默认 /metrics
+	Path string
+	// Buckets 请求耗时分桶，默认 DefaultBuckets
+	Buckets []float64
+	// DisableRuntime 不输出 Go 运行时指标
+	DisableRuntime bool
+}
+
+// Metrics HTTP 请求指标
+type Metrics struct {
+	options  Options
+	registry *Registry
+
+	requests *CounterVec
+	duration *HistogramVec
+	inFlight *GaugeVec
+	// 启动时间，用于 process_start_time_seconds
+	startTime time.Time
+}
+
+// New 创建指标
+func New(options Options) *Metrics {
+	if options.Namespace == "" {
+		options.Namespace = "http"
+	}
+	if options.Path == "" {
+		options.Path = "/metrics"
+	}
+	registry := NewRegistry()
+	m := &Metrics{
+		options:   options,
+		registry:  registry,
+		startTime: time.Now(),
+	}
+	m.requests = registry.NewCounterVec(options.Namespace+"_requests_total",
+		"Total number of HTTP requests.", "method", "route", "status")
+	m.duration = registry.NewHistogramVec(options.Namespace+"_request_duration_seconds",
+		"HTTP request latency in seconds.", options.Buckets, "method", "route", "status")
+	m.inFlight = registry.NewGaugeVec(options.Namespace+"_requests_in_flight",
+		"Number of HTTP requests currently being served.", "method")
+	if !options.DisableRuntime {
+		registry.register(collectorFunc(m.writeRuntime))
+	}
+	return m
+}
+
+// Registry 返回指标注册表，可以注册业务指标，和 HTTP 指标一起输出
+func (m *Metrics) Registry() *Registry {
+	return m.registry
+}
+
+// Register 注册全局中间件和指标路由
+func (m *Metrics) Register(e *engine.Engine) {
+	e.Use(m.Middleware())
+	e.GET(m.options.Path, m.Handler())
+}
+
+// Middleware 记录请求数、耗时和正在处理的请求数
+func (m *Metrics) Middleware() engine.HandlerFunc {
+	return func(c *engine.Context) {
+		start := time.Now()
+		method := methodLabel(c.Method)
+		m.inFlight.Inc(method)
+		defer m.inFlight.Dec(method)
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := c.ResponseStatus()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		statusText := strconv.Itoa(status)
+		m.requests.Inc(method, route, statusText)
+		m.duration.Observe(time.Since(start).Seconds(), method, route, statusText)
+	}
+}
+
+// Handler 按 Prometheus 文本格式输出指标
+func (m *Metrics) Handler() engine.HandlerFunc {
+	return func(c *engine.Context) {
+		var buf bytes.Buffer
+		if err := m.registry.Write(&buf); err != nil {
+			c.StringFormat(http.StatusInternalServerError, "%v", err)
+			return
+		}
+		c.SetHeader("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		c.Status(http.StatusOK)
+		_, _ = c.W.Write(buf.Bytes())
+	}
+}
+
+func (m *Metrics) writeRuntime(w io.Writer) error {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	metrics := []struct {
+		name       string
+		help       string
+		metricType string
+		value      float64
+	}{
+		{"go_goroutines", "Number of goroutines that currently exist.", "gauge", float64(runtime.NumGoroutine())},
+		{"go_threads", "Number of OS threads created.", "gauge", float64(threadCount())},
+		{"go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", "gauge", float64(stats.Alloc)},
+		{"go_memstats_alloc_bytes_total", "Total number of bytes allocated, even if freed.", "counter", float64(stats.TotalAlloc)},
+		{"go_memstats_sys_bytes", "Number of bytes obtained from system.", "gauge", float64(stats.Sys)},
+		{"go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", "gauge", float64(stats.HeapInuse)},
+		{"go_memstats_heap_objects", "Number of allocated objects.", "gauge", float64(stats.HeapObjects)},
+		{"go_gc_cycles_total", "Number of completed GC cycles.", "counter", float64(stats.NumGC)},
+		{"go_gc_pause_seconds_total", "Total GC pause time in seconds.", "counter", float64(stats.PauseTotalNs) / 1e9},
+		{"process_start_time_seconds", "Start time of the process since unix epoch in seconds.", "gauge", float64(m.startTime.UnixNano()) / 1e9},
+	}
+	for _, metric := range metrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n",
+			metric.name, metric.help, metric.name, metric.metricType, metric.name, formatFloat(metric.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func threadCount() int {
+	n, _ := runtime.ThreadCreateProfile(nil)
+	return n
+}
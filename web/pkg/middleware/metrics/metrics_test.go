@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogramVec_Write(t *testing.T) {
+	registry := NewRegistry()
+	h := registry.NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "path")
+	h.Observe(0.05, `/a"b`)
+	h.Observe(0.5, `/a"b`)
+	h.Observe(5, `/a"b`)
+
+	var sb strings.Builder
+	assert.Nil(t, registry.Write(&sb))
+	assert.Equal(t, `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{path="/a\"b",le="0.1"} 1
+latency_seconds_bucket{path="/a\"b",le="1"} 2
+latency_seconds_bucket{path="/a\"b",le="+Inf"} 3
+latency_seconds_sum{path="/a\"b"} 5.55
+latency_seconds_count{path="/a\"b"} 3
+`, sb.String())
+}
+
+func TestMetrics(t *testing.T) {
+	m := New(Options{})
+	e := engine.New()
+	m.Register(e)
+	e.GET("/user/:id", func(c *engine.Context) {
+		c.StringOk("ok")
+	})
+	e.POST("/user/:id", func(c *engine.Context) {
+		c.StringFormat(http.StatusBadRequest, "bad")
+	})
+
+	for _, path := range []string{"/user/1", "/user/2", "/missing/3"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/user/1", nil))
+	for _, method := range []string{"FOO", "BAR"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/user/1", nil))
+	}
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4"))
+	body := w.Body.String()
+
+	// route 标签使用注册模式而不是原始路径
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/user/:id",status="200"} 2`)
+	assert.Contains(t, body, `http_requests_total{method="POST",route="/user/:id",status="400"} 1`)
+	assert.Contains(t, body, `http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	// 非标准方法合并成 OTHER
+	assert.Contains(t, body, `http_requests_total{method="OTHER",route="unmatched",status="404"} 2`)
+	assert.NotContains(t, body, "FOO")
+	assert.Contains(t, body, `http_request_duration_seconds_count{method="GET",route="/user/:id",status="200"} 2`)
+	// 抓取请求本身正在处理
+	assert.Contains(t, body, `http_requests_in_flight{method="GET"} 1`)
+	assert.Contains(t, body, "# TYPE go_goroutines gauge")
+	assert.NotContains(t, body, "/user/1")
+}
@@ -0,0 +1,137 @@
+package rbac
+
+import (
+	"net/http"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+)
+
+// MetaKey 路由访问要求保存在路由元数据里的 key
+const MetaKey = "rbac.requirement"
+
+// RolesKey 默认从 Context.Keys 这个 key 读取当前用户角色，认证中间件负责写入
+const RolesKey = "roles"
+
+// Requirement 路由访问要求
+type Requirement struct {
+	// Roles 满足其中一个角色即可，为空不校验角色
+	Roles []string
+	// Permissions 必须全部满足，权限也可以用来表示 OAuth scope，为空不校验权限
+	Permissions []string
+}
+
+// RequireRoles 声明路由需要的角色，满足其中一个即可
+func RequireRoles(roles ...string) engine.RouteOption {
+	return func(route *engine.Route) {
+		requirement := routeRequirement(route)
+		requirement.Roles = append(requirement.Roles, roles...)
+		engine.WithMeta(MetaKey, requirement)(route)
+	}
+}
+
+// RequirePermissions 声明路由需要的权限，必须全部满足
+func RequirePermissions(permissions ...string) engine.RouteOption {
+	return func(route *engine.Route) {
+		requirement := routeRequirement(route)
+		requirement.Permissions = append(requirement.Permissions, permissions...)
+		engine.WithMeta(MetaKey, requirement)(route)
+	}
+}
+
+func routeRequirement(route *engine.Route) Requirement {
+	requirement, _ := route.Meta[MetaKey].(Requirement)
+	return requirement
+}
+
+// PolicySource 角色权限策略来源，可以是内存、配置文件或者数据库
+type PolicySource interface {
+	// Permissions 返回角色拥有的权限，"*" 表示拥有所有权限
+	Permissions(role string) ([]string, error)
+}
+
+// StaticPolicy 内存策略，角色到权限列表的映射
+type StaticPolicy map[string][]string
+
+func (p StaticPolicy) Permissions(role string) ([]string, error) {
+	return p[role], nil
+}
+
+// Options RBAC 配置
+type Options struct {
+	Policy PolicySource
+	// Roles 返回当前用户角色，默认读取 Context.Keys[RolesKey]
+	Roles func(c *engine.Context) []string
+}
+
+// RBAC 根据路由元数据里的 Requirement 校验当前用户角色和权限，不满足返回 403
+// 没有声明 Requirement 的路由不做校验
+func RBAC(options Options) engine.HandlerFunc {
+	if options.Policy == nil {
+		panic("rbac: Options.Policy is required")
+	}
+	if options.Roles == nil {
+		options.Roles = func(c *engine.Context) []string {
+			v, _ := c.Get(RolesKey)
+			roles, _ := v.([]string)
+			return roles
+		}
+	}
+
+	return func(c *engine.Context) {
+		v, ok := c.RouteMeta(MetaKey)
+		if !ok {
+			return
+		}
+		requirement := v.(Requirement)
+		allowed, err := authorize(options.Policy, options.Roles(c), requirement)
+		if err != nil {
+			c.StringFormat(http.StatusInternalServerError, "Internal Server Error")
+			c.Abort()
+			return
+		}
+		if !allowed {
+			c.StringFormat(http.StatusForbidden, "Forbidden")
+			c.Abort()
+		}
+	}
+}
+
+func authorize(policy PolicySource, roles []string, requirement Requirement) (bool, error) {
+	if len(requirement.Roles) > 0 && !containsAny(roles, requirement.Roles) {
+		return false, nil
+	}
+	if len(requirement.Permissions) == 0 {
+		return true, nil
+	}
+
+	granted := make(map[string]bool)
+	for _, role := range roles {
+		permissions, err := policy.Permissions(role)
+		if err != nil {
+			return false, err
+		}
+		for _, permission := range permissions {
+			granted[permission] = true
+		}
+	}
+	if granted["*"] {
+		return true, nil
+	}
+	for _, permission := range requirement.Permissions {
+		if !granted[permission] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func containsAny(values []string, targets []string) bool {
+	for _, v := range values {
+		for _, t := range targets {
+			if v == t {
+				return true
+			}
+		}
+	}
+	return false
+}
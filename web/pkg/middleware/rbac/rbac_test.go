@@ -0,0 +1,70 @@
+package rbac
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRBAC(t *testing.T) {
+	policy := StaticPolicy{
+		"admin":  {"*"},
+		"editor": {"blog:read", "blog:write"},
+		"viewer": {"blog:read"},
+	}
+	e := engine.New()
+	// 模拟认证中间件，从请求头读取角色
+	e.Use(func(c *engine.Context) {
+		if roles := c.GetHeader("X-Roles"); roles != "" {
+			c.Set(RolesKey, strings.Split(roles, ","))
+		}
+	})
+	e.Use(RBAC(Options{Policy: policy}))
+
+	ok := func(c *engine.Context) { c.StringOk("ok") }
+	e.GET("/public", ok)
+	e.GET("/blog", ok, RequirePermissions("blog:read"))
+	e.POST("/blog", ok, RequirePermissions("blog:write"))
+	e.GET("/admin", ok, RequireRoles("admin"))
+	e.POST("/admin/blog", ok, RequireRoles("admin", "editor"), RequirePermissions("blog:write"))
+
+	testCases := []struct {
+		method string
+		path   string
+		roles  string
+		code   int
+	}{
+		{http.MethodGet, "/public", "", http.StatusOK},
+		{http.MethodGet, "/blog", "", http.StatusForbidden},
+		{http.MethodGet, "/blog", "viewer", http.StatusOK},
+		{http.MethodPost, "/blog", "viewer", http.StatusForbidden},
+		{http.MethodPost, "/blog", "viewer,editor", http.StatusOK},
+		{http.MethodPost, "/blog", "admin", http.StatusOK},
+		{http.MethodGet, "/admin", "editor", http.StatusForbidden},
+		{http.MethodGet, "/admin", "admin", http.StatusOK},
+		{http.MethodPost, "/admin/blog", "editor", http.StatusOK},
+		{http.MethodPost, "/admin/blog", "viewer", http.StatusForbidden},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.Header.Set("X-Roles", tc.roles)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		assert.Equal(t, tc.code, w.Code, "%s %s %s", tc.method, tc.path, tc.roles)
+	}
+}
+
+func TestContext_RouteMeta(t *testing.T) {
+	e := engine.New()
+	var requirement any
+	e.GET("/blog", func(c *engine.Context) {
+		requirement, _ = c.RouteMeta(MetaKey)
+	}, RequireRoles("admin"), RequirePermissions("blog:read"))
+
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/blog", nil))
+	assert.Equal(t, Requirement{Roles: []string{"admin"}, Permissions: []string{"blog:read"}}, requirement)
+}
@@ -0,0 +1,164 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+)
+
+const (
+	// Key 请求 ID 保存在 Context.Keys 里的 key
+	Key = "request_id"
+	// HeaderName 默认读取和返回请求 ID 的请求头
+	HeaderName = "X-Request-ID"
+	// maxLength 上游传入请求 ID 的最大长度
+	maxLength = 128
+	// loggerKey 请求 logger 保存在 Context.Keys 里的 key
+	loggerKey = "github.com/2456868764/go-learning/web/pkg/middleware/requestid.logger"
+)
+
+// Options 请求 ID 配置
+type Options struct {
+	// Header 读取和返回请求 ID 的请求头，默认 X-Request-ID
+	Header string
+	// Generator 生成请求 ID，默认生成 UUID v4
+	Generator func() string
+	// Validator 校验上游传入的请求 ID，不通过时重新生成，默认 Valid
+	Validator func(id string) bool
+}
+
+// New 请求 ID 中间件
+// 请求头带有合法请求 ID 时沿用，否则生成新的，保存到 Context 和 request context 并写入响应头
+func New(options Options) engine.HandlerFunc {
+	if options.Header == "" {
+		options.Header = HeaderName
+	}
+	if options.Generator == nil {
+		options.Generator = NewID
+	}
+	if options.Validator == nil {
+		options.Validator = Valid
+	}
+	return func(c *engine.Context) {
+		id := c.GetHeader(options.Header)
+		if !options.Validator(id) {
+			id = options.Generator()
+		}
+		c.Set(Key, id)
+		ctx := NewContext(c.R.Context(), id)
+		// 记录配置的请求头，Inject 调用下游时使用同一个请求头
+		ctx = context.WithValue(ctx, headerContextKey{}, options.Header)
+		c.R = c.R.WithContext(ctx)
+		c.SetHeader(options.Header, id)
+		c.Next()
+	}
+}
+
+// RequestID 使用默认配置的请求 ID 中间件
+func RequestID() engine.HandlerFunc {
+	return New(Options{})
+}
+
+// Get 返回当前请求的请求 ID，没有使用中间件返回空字符串
+func Get(c *engine.Context) string {
+	return c.GetString(Key)
+}
+
+// Valid 请求 ID 长度不超过 128，只能包含字母、数字和 -_.:
+// 限制字符集避免上游传入换行等字符污染日志
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		ch := id[i]
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9':
+		case ch == '-', ch == '_', ch == '.', ch == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// NewID 生成 UUID v4 格式的请求 ID
+func NewID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:])
+	return string(buf)
+}
+
+type contextKey struct{}
+
+// headerContextKey 中间件配置的请求头保存在 context.Context 里的 key
+type headerContextKey struct{}
+
+// NewContext 把请求 ID 放入 context.Context
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext 从 context.Context 取出请求 ID，没有返回空字符串
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Inject 把 ctx 里的请求 ID 写入请求头，调用下游服务时使用，保证同一个请求跨服务可以串起来
+// 请求头使用中间件配置的 Options.Header，ctx 不是来自中间件时使用 HeaderName
+func Inject(ctx context.Context, header http.Header) {
+	name, _ := ctx.Value(headerContextKey{}).(string)
+	if name == "" {
+		name = HeaderName
+	}
+	InjectHeader(ctx, header, name)
+}
+
+// InjectHeader 把 ctx 里的请求 ID 写入名字为 name 的请求头
+func InjectHeader(ctx context.Context, header http.Header, name string) {
+	if id := FromContext(ctx); id != "" {
+		header.Set(name, id)
+	}
+}
+
+// Logger 返回带请求 ID 前缀的 logger，输出和格式沿用 log 标准 logger
+// 每行日志前缀为 "request_id=xxx "，方便按请求 ID 检索
+func Logger(c *engine.Context) *log.Logger {
+	if v, ok := c.Get(loggerKey); ok {
+		return v.(*log.Logger)
+	}
+	logger := NewLogger(log.Default(), Get(c))
+	c.Set(loggerKey, logger)
+	return logger
+}
+
+// NewLogger 基于 base 创建带请求 ID 前缀的 logger，id 为空时不加前缀
+func NewLogger(base *log.Logger, id string) *log.Logger {
+	prefix := base.Prefix()
+	if id != "" {
+		prefix += Key + "=" + id + " "
+	}
+	return log.New(base.Writer(), prefix, base.Flags()|log.Lmsgprefix)
+}
+
+// Printf 带请求 ID 输出日志
+func Printf(c *engine.Context, format string, v ...any) {
+	Logger(c).Printf(format, v...)
+}
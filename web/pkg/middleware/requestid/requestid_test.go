@@ -0,0 +1,124 @@
+package requestid
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestRequestID(t *testing.T) {
+	e := engine.New()
+	e.Use(RequestID())
+	e.GET("/id", func(c *engine.Context) {
+		assert.Equal(t, Get(c), FromContext(c.R.Context()))
+		c.StringOk(Get(c))
+	})
+
+	// 沿用上游请求 ID
+	req := httptest.NewRequest(http.MethodGet, "/id", nil)
+	req.Header.Set(HeaderName, "upstream-id.1:2")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, "upstream-id.1:2", w.Body.String())
+	assert.Equal(t, "upstream-id.1:2", w.Header().Get(HeaderName))
+
+	// 不合法的请求 ID 重新生成
+	invalid := []string{"", "bad id", "bad\nid", strings.Repeat("a", maxLength+1)}
+	for _, id := range invalid {
+		req = httptest.NewRequest(http.MethodGet, "/id", nil)
+		req.Header.Set(HeaderName, id)
+		w = httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		assert.Regexp(t, uuidPattern, w.Body.String())
+		assert.Equal(t, w.Body.String(), w.Header().Get(HeaderName))
+	}
+
+	// 没有命中路由也返回请求 ID
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Regexp(t, uuidPattern, w.Header().Get(HeaderName))
+}
+
+func TestOptions(t *testing.T) {
+	e := engine.New()
+	e.Use(New(Options{
+		Header:    "X-Correlation-ID",
+		Generator: func() string { return "generated" },
+		Validator: func(id string) bool { return strings.HasPrefix(id, "ok-") },
+	}))
+	e.GET("/id", func(c *engine.Context) {
+		c.StringOk(Get(c))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/id", nil)
+	req.Header.Set("X-Correlation-ID", "ok-1")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, "ok-1", w.Header().Get("X-Correlation-ID"))
+
+	req = httptest.NewRequest(http.MethodGet, "/id", nil)
+	req.Header.Set("X-Correlation-ID", "other")
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, "generated", w.Body.String())
+}
+
+func TestNewID(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id := NewID()
+		assert.Regexp(t, uuidPattern, id)
+		assert.True(t, Valid(id))
+		assert.False(t, seen[id])
+		seen[id] = true
+	}
+}
+
+func TestLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewLogger(log.New(buf, "app: ", 0), "abc-123")
+	logger.Printf("hello %s", "world")
+	assert.Equal(t, "app: request_id=abc-123 hello world\n", buf.String())
+
+	c := engine.NewContext(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	c.Set(Key, "abc-123")
+	assert.Same(t, Logger(c), Logger(c))
+	assert.Equal(t, "request_id=abc-123 ", Logger(c).Prefix())
+}
+
+func TestInject(t *testing.T) {
+	header := http.Header{}
+	Inject(context.Background(), header)
+	assert.Empty(t, header.Get(HeaderName))
+
+	Inject(NewContext(context.Background(), "abc-123"), header)
+	assert.Equal(t, "abc-123", header.Get(HeaderName))
+
+	header = http.Header{}
+	InjectHeader(NewContext(context.Background(), "abc-123"), header, "X-Trace")
+	assert.Equal(t, "abc-123", header.Get("X-Trace"))
+
+	// 使用中间件配置的请求头调用下游
+	e := engine.New()
+	e.Use(New(Options{Header: "X-Correlation-ID"}))
+	e.GET("/call", func(c *engine.Context) {
+		out := http.Header{}
+		Inject(c.R.Context(), out)
+		assert.Equal(t, "ok-1", out.Get("X-Correlation-ID"))
+		assert.Empty(t, out.Get(HeaderName))
+	})
+	req := httptest.NewRequest(http.MethodGet, "/call", nil)
+	req.Header.Set("X-Correlation-ID", "ok-1")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+}
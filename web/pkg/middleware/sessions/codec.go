@@ -0,0 +1,158 @@
+package sessions
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrorInvalidCookie = errors.New("sessions: invalid cookie value")
+var ErrorInvalidSignature = errors.New("sessions: invalid cookie signature")
+var ErrorCookieExpired = errors.New("sessions: cookie expired")
+
+func init() {
+	// 闪存消息以 []any 保存在会话里
+	gob.Register([]any{})
+}
+
+// codec 对 cookie 值做 HMAC-SHA256 签名，配置了加密 key 时先用 AES-GCM 加密
+// cookie 值格式: 时间戳|base64(数据)|base64(签名)
+type codec struct {
+	hashKey []byte
+	aead    cipher.AEAD
+}
+
+// newCodecs 根据 key 对创建 codec 列表，每一对 key 依次是签名 key 和加密 key，加密 key 可以为 nil
+// 第一对 key 用来编码，所有 key 都会用来解码，新 key 放在前面，旧 key 放在后面就可以平滑轮换 key
+func newCodecs(keyPairs ...[]byte) []*codec {
+	if len(keyPairs) == 0 {
+		panic("sessions: at least one hash key is required")
+	}
+	codecs := make([]*codec, 0, (len(keyPairs)+1)/2)
+	for i := 0; i < len(keyPairs); i += 2 {
+		if len(keyPairs[i]) == 0 {
+			panic("sessions: hash key must not be empty")
+		}
+		c := &codec{hashKey: keyPairs[i]}
+		if i+1 < len(keyPairs) && keyPairs[i+1] != nil {
+			// 加密 key 长度必须是 16、24 或 32，分别对应 AES-128、AES-192、AES-256
+			block, err := aes.NewCipher(keyPairs[i+1])
+			if err != nil {
+				panic(fmt.Sprintf("sessions: invalid block key: %v", err))
+			}
+			aead, err := cipher.NewGCM(block)
+			if err != nil {
+				panic(fmt.Sprintf("sessions: invalid block key: %v", err))
+			}
+			c.aead = aead
+		}
+		codecs = append(codecs, c)
+	}
+	return codecs
+}
+
+func (c *codec) encode(name string, value []byte) (string, error) {
+	if c.aead != nil {
+		nonce := make([]byte, c.aead.NonceSize())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return "", err
+		}
+		// cookie 名字作为附加数据，防止把一个 cookie 的值挪到另一个 cookie 使用
+		value = c.aead.Seal(nonce, nonce, value, []byte(name))
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	payload := base64.RawURLEncoding.EncodeToString(value)
+	mac := c.sign(name, timestamp, payload)
+	return timestamp + "|" + payload + "|" + base64.RawURLEncoding.EncodeToString(mac), nil
+}
+
+// decode 校验签名和有效期并解密，maxAge 小于等于 0 不校验有效期
+func (c *codec) decode(name string, value string, maxAge int) ([]byte, error) {
+	parts := strings.Split(value, "|")
+	if len(parts) != 3 {
+		return nil, ErrorInvalidCookie
+	}
+	timestamp, payload := parts[0], parts[1]
+	mac, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrorInvalidCookie
+	}
+	if !hmac.Equal(mac, c.sign(name, timestamp, payload)) {
+		return nil, ErrorInvalidSignature
+	}
+	created, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrorInvalidCookie
+	}
+	if maxAge > 0 && created+int64(maxAge) < time.Now().Unix() {
+		return nil, ErrorCookieExpired
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrorInvalidCookie
+	}
+	if c.aead != nil {
+		nonceSize := c.aead.NonceSize()
+		if len(data) < nonceSize {
+			return nil, ErrorInvalidCookie
+		}
+		data, err = c.aead.Open(nil, data[:nonceSize], data[nonceSize:], []byte(name))
+		if err != nil {
+			return nil, ErrorInvalidCookie
+		}
+	}
+	return data, nil
+}
+
+func (c *codec) sign(name string, timestamp string, payload string) []byte {
+	h := hmac.New(sha256.New, c.hashKey)
+	h.Write([]byte(name + "|" + timestamp + "|" + payload))
+	return h.Sum(nil)
+}
+
+// encodeCookie 使用第一个 codec 编码
+func encodeCookie(codecs []*codec, name string, value []byte) (string, error) {
+	return codecs[0].encode(name, value)
+}
+
+// decodeCookie 依次尝试所有 codec 解码，都失败返回第一个错误
+func decodeCookie(codecs []*codec, name string, value string, maxAge int) ([]byte, error) {
+	var firstErr error
+	for _, c := range codecs {
+		data, err := c.decode(name, value, maxAge)
+		if err == nil {
+			return data, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+func serialize(values map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(values); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func deserialize(data []byte) (map[string]any, error) {
+	values := make(map[string]any)
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
@@ -0,0 +1,66 @@
+package sessions
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+)
+
+// ErrorCookieTooLarge 编码后 cookie 超过浏览器限制
+var ErrorCookieTooLarge = errors.New("sessions: encoded cookie value exceeds 4096 bytes")
+
+// maxCookieLength 浏览器对单个 cookie 大小的限制
+const maxCookieLength = 4096
+
+// CookieStore 会话数据签名加密后全部保存在 cookie 里
+type CookieStore struct {
+	codecs []*codec
+	// MaxAge 签名有效期，单位秒，超过有效期的 cookie 视为无效，小于等于 0 不校验
+	MaxAge int
+}
+
+// NewCookieStore 创建 cookie 存储，keyPairs 依次是签名 key 和加密 key，加密 key 为 nil 时只签名不加密
+// 可以传入多对 key 轮换，第一对用于编码，所有 key 都可以解码
+func NewCookieStore(keyPairs ...[]byte) *CookieStore {
+	return &CookieStore{
+		codecs: newCodecs(keyPairs...),
+		MaxAge: DefaultOptions.MaxAge,
+	}
+}
+
+func (s *CookieStore) Load(c *engine.Context, name string) (string, map[string]any, error) {
+	cookie, err := c.R.Cookie(name)
+	if err != nil {
+		return "", make(map[string]any), nil
+	}
+	data, err := decodeCookie(s.codecs, name, cookie.Value, s.MaxAge)
+	if err != nil {
+		return "", nil, err
+	}
+	values, err := deserialize(data)
+	if err != nil {
+		return "", nil, err
+	}
+	return "", values, nil
+}
+
+func (s *CookieStore) Save(c *engine.Context, name string, id string, values map[string]any, options Options) (string, error) {
+	if options.MaxAge < 0 {
+		http.SetCookie(c.W, newCookie(name, "", options))
+		return "", nil
+	}
+	data, err := serialize(values)
+	if err != nil {
+		return "", err
+	}
+	value, err := encodeCookie(s.codecs, name, data)
+	if err != nil {
+		return "", err
+	}
+	if len(name)+len(value) > maxCookieLength {
+		return "", ErrorCookieTooLarge
+	}
+	http.SetCookie(c.W, newCookie(name, value, options))
+	return "", nil
+}
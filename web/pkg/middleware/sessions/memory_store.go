@@ -0,0 +1,128 @@
+package sessions
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+)
+
+// MemoryStore 服务端内存存储，cookie 里只保存签名后的会话 ID，会话数据超过 ttl 没有保存就过期
+type MemoryStore struct {
+	codecs []*codec
+	ttl    time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]memoryEntry
+	// 上次清理过期会话的时间
+	lastGC time.Time
+}
+
+type memoryEntry struct {
+	values  map[string]any
+	expires time.Time
+}
+
+// NewMemoryStore 创建内存存储，keyPairs 用于签名会话 ID cookie，和 NewCookieStore 一样
+func NewMemoryStore(ttl time.Duration, keyPairs ...[]byte) *MemoryStore {
+	return &MemoryStore{
+		codecs:   newCodecs(keyPairs...),
+		ttl:      ttl,
+		sessions: make(map[string]memoryEntry),
+		lastGC:   time.Now(),
+	}
+}
+
+func (s *MemoryStore) Load(c *engine.Context, name string) (string, map[string]any, error) {
+	cookie, err := c.R.Cookie(name)
+	if err != nil {
+		return "", make(map[string]any), nil
+	}
+	data, err := decodeCookie(s.codecs, name, cookie.Value, 0)
+	if err != nil {
+		return "", nil, err
+	}
+	id := string(data)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.sessions[id]
+	if !ok || time.Now().After(entry.expires) {
+		delete(s.sessions, id)
+		return "", make(map[string]any), nil
+	}
+	return id, copyValues(entry.values), nil
+}
+
+func (s *MemoryStore) Save(c *engine.Context, name string, id string, values map[string]any, options Options) (string, error) {
+	if options.MaxAge < 0 {
+		if id != "" {
+			s.mu.Lock()
+			delete(s.sessions, id)
+			s.mu.Unlock()
+		}
+		http.SetCookie(c.W, newCookie(name, "", options))
+		return "", nil
+	}
+
+	if id == "" {
+		newID, err := newSessionID()
+		if err != nil {
+			return "", err
+		}
+		id = newID
+	}
+	value, err := encodeCookie(s.codecs, name, []byte(id))
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	s.sessions[id] = memoryEntry{values: copyValues(values), expires: now.Add(s.ttl)}
+	s.gc(now)
+	s.mu.Unlock()
+
+	http.SetCookie(c.W, newCookie(name, value, options))
+	return id, nil
+}
+
+// Len 当前保存的会话数量，包括还没有清理的过期会话
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// gc 每隔 ttl 清理一次过期会话，调用方需要持有锁
+func (s *MemoryStore) gc(now time.Time) {
+	if now.Sub(s.lastGC) < s.ttl {
+		return
+	}
+	for id, entry := range s.sessions {
+		if now.After(entry.expires) {
+			delete(s.sessions, id)
+		}
+	}
+	s.lastGC = now
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func copyValues(values map[string]any) map[string]any {
+	copied := make(map[string]any, len(values))
+	for k, v := range values {
+		copied[k] = v
+	}
+	return copied
+}
@@ -0,0 +1,154 @@
+package sessions
+
+import (
+	"net/http"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+)
+
+// contextKey 会话保存在 Context.Keys 里的 key
+const contextKey = "github.com/2456868764/go-learning/web/pkg/middleware/sessions"
+
+// flashKey 闪存消息保存在会话里的 key
+const flashKey = "_flash"
+
+// Session 请求会话，修改后需要调用 Save 才会写回存储和响应
+type Session interface {
+	// ID 会话 ID，cookie 存储没有 ID 返回空字符串
+	ID() string
+	Get(key string) any
+	Set(key string, value any)
+	Delete(key string)
+	// Clear 清空会话所有数据
+	Clear()
+	// Flash 添加一条闪存消息，闪存消息被 Flashes 读取一次后删除
+	Flash(value any)
+	// Flashes 读取并删除所有闪存消息
+	Flashes() []any
+	// Options 修改本次会话的 cookie 配置
+	Options(options Options)
+	// Save 保存会话，必须在写入响应状态码之前调用
+	Save() error
+}
+
+// Options 会话 cookie 配置
+type Options struct {
+	Path   string
+	Domain string
+	// MaxAge 等于 0 表示会话 cookie，小于 0 表示删除会话
+	MaxAge   int
+	Secure   bool
+	HttpOnly bool
+	SameSite http.SameSite
+}
+
+// DefaultOptions 默认 cookie 配置，有效期 30 天
+var DefaultOptions = Options{
+	Path:     "/",
+	MaxAge:   86400 * 30,
+	HttpOnly: true,
+	SameSite: http.SameSiteLaxMode,
+}
+
+// Store 会话存储
+type Store interface {
+	// Load 从请求中加载会话，会话不存在或者校验失败时返回新的空会话
+	Load(c *engine.Context, name string) (id string, values map[string]any, err error)
+	// Save 保存会话数据，并在响应写入会话 cookie，返回保存后的会话 ID
+	Save(c *engine.Context, name string, id string, values map[string]any, options Options) (string, error)
+}
+
+// Sessions 会话中间件，name 是会话 cookie 名字
+func Sessions(name string, store Store) engine.HandlerFunc {
+	return func(c *engine.Context) {
+		s := &session{
+			name:    name,
+			store:   store,
+			c:       c,
+			options: DefaultOptions,
+		}
+		id, values, err := store.Load(c, name)
+		if err != nil || values == nil {
+			id, values = "", make(map[string]any)
+		}
+		s.id = id
+		s.values = values
+		c.Set(contextKey, s)
+		c.Next()
+	}
+}
+
+// Default 返回 Sessions 中间件创建的会话，没有使用 Sessions 中间件会 panic
+func Default(c *engine.Context) Session {
+	s, ok := c.Get(contextKey)
+	if !ok {
+		panic("sessions: Sessions middleware is not registered")
+	}
+	return s.(Session)
+}
+
+type session struct {
+	name    string
+	id      string
+	values  map[string]any
+	store   Store
+	c       *engine.Context
+	options Options
+}
+
+func (s *session) ID() string {
+	return s.id
+}
+
+func (s *session) Get(key string) any {
+	return s.values[key]
+}
+
+func (s *session) Set(key string, value any) {
+	s.values[key] = value
+}
+
+func (s *session) Delete(key string) {
+	delete(s.values, key)
+}
+
+func (s *session) Clear() {
+	s.values = make(map[string]any)
+}
+
+func (s *session) Flash(value any) {
+	flashes, _ := s.values[flashKey].([]any)
+	s.values[flashKey] = append(flashes, value)
+}
+
+func (s *session) Flashes() []any {
+	flashes, _ := s.values[flashKey].([]any)
+	delete(s.values, flashKey)
+	return flashes
+}
+
+func (s *session) Options(options Options) {
+	s.options = options
+}
+
+func (s *session) Save() error {
+	id, err := s.store.Save(s.c, s.name, s.id, s.values, s.options)
+	if err != nil {
+		return err
+	}
+	s.id = id
+	return nil
+}
+
+func newCookie(name string, value string, options Options) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     options.Path,
+		Domain:   options.Domain,
+		MaxAge:   options.MaxAge,
+		Secure:   options.Secure,
+		HttpOnly: options.HttpOnly,
+		SameSite: options.SameSite,
+	}
+}
@@ -0,0 +1,118 @@
+package sessions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	hashKey  = []byte("0123456789abcdef0123456789abcdef")
+	blockKey = []byte("abcdef0123456789abcdef0123456789")
+)
+
+func newSessionEngine(store Store) *engine.Engine {
+	e := engine.New()
+	e.Use(Sessions("session", store))
+	e.GET("/set", func(c *engine.Context) {
+		s := Default(c)
+		s.Set("user", c.Query("user"))
+		s.Flash("welcome")
+		if err := s.Save(); err != nil {
+			c.StringFormat(http.StatusInternalServerError, "%v", err)
+			return
+		}
+		c.StringOk("ok")
+	})
+	e.GET("/get", func(c *engine.Context) {
+		s := Default(c)
+		user, _ := s.Get("user").(string)
+		flashes := s.Flashes()
+		_ = s.Save()
+		c.StringFormat(http.StatusOK, "%s %d", user, len(flashes))
+	})
+	e.GET("/clear", func(c *engine.Context) {
+		s := Default(c)
+		s.Clear()
+		s.Options(Options{Path: "/", MaxAge: -1})
+		_ = s.Save()
+		c.StringOk("ok")
+	})
+	return e
+}
+
+func doRequest(e *engine.Engine, path string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestCookieStore(t *testing.T) {
+	e := newSessionEngine(NewCookieStore(hashKey, blockKey))
+
+	w := doRequest(e, "/set?user=jun", nil)
+	cookies := w.Result().Cookies()
+	assert.Equal(t, 1, len(cookies))
+	// 加密后 cookie 里看不到明文
+	assert.False(t, strings.Contains(cookies[0].Value, "jun"))
+
+	w = doRequest(e, "/get", cookies)
+	assert.Equal(t, "jun 1", w.Body.String())
+
+	// 闪存消息读取一次后删除
+	w = doRequest(e, "/get", w.Result().Cookies())
+	assert.Equal(t, "jun 0", w.Body.String())
+
+	// 篡改后的 cookie 视为新会话
+	tampered := *cookies[0]
+	tampered.Value = "1" + tampered.Value
+	w = doRequest(e, "/get", []*http.Cookie{&tampered})
+	assert.Equal(t, " 0", w.Body.String())
+
+	w = doRequest(e, "/clear", cookies)
+	assert.Equal(t, -1, w.Result().Cookies()[0].MaxAge)
+}
+
+func TestCookieStore_KeyRotation(t *testing.T) {
+	oldKey := []byte("old-hash-key-old-hash-key-old-ha")
+	oldEngine := newSessionEngine(NewCookieStore(oldKey, blockKey))
+	cookies := doRequest(oldEngine, "/set?user=jun", nil).Result().Cookies()
+
+	// 新 key 放在前面，旧 key 仍然可以解码
+	rotated := newSessionEngine(NewCookieStore(hashKey, blockKey, oldKey, blockKey))
+	w := doRequest(rotated, "/get", cookies)
+	assert.Equal(t, "jun 1", w.Body.String())
+
+	// 去掉旧 key 后解码失败
+	w = doRequest(newSessionEngine(NewCookieStore(hashKey, blockKey)), "/get", cookies)
+	assert.Equal(t, " 0", w.Body.String())
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore(50*time.Millisecond, hashKey)
+	e := newSessionEngine(store)
+
+	cookies := doRequest(e, "/set?user=jun", nil).Result().Cookies()
+	assert.Equal(t, 1, store.Len())
+
+	w := doRequest(e, "/get", cookies)
+	assert.Equal(t, "jun 1", w.Body.String())
+
+	time.Sleep(60 * time.Millisecond)
+	w = doRequest(e, "/get", cookies)
+	assert.Equal(t, " 0", w.Body.String())
+
+	cookies = doRequest(e, "/set?user=jun", nil).Result().Cookies()
+	doRequest(e, "/clear", cookies)
+	w = doRequest(e, "/get", cookies)
+	assert.Equal(t, " 0", w.Body.String())
+}
@@ -0,0 +1,131 @@
+package tracing
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const (
+	traceparentHeader = "traceparent"
+	tracestateHeader  = "tracestate"
+	// flagSampled traceparent trace-flags 里的采样位
+	flagSampled = 0x01
+	// maxTracestateLength W3C 规定 tracestate 最长 512 字符
+	maxTracestateLength = 512
+)
+
+var ErrorInvalidTraceparent = errors.New("tracing: invalid traceparent")
+
+// TraceID 16 字节 trace ID
+type TraceID [16]byte
+
+// SpanID 8 字节 span ID
+type SpanID [8]byte
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// SpanContext 跨进程传递的 span 信息
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Flags      byte
+	TraceState string
+	// Remote 是否从请求头解析得到
+	Remote bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&flagSampled != 0
+}
+
+// Traceparent 生成 traceparent 头，格式 00-traceId-spanId-flags
+func (sc SpanContext) Traceparent() string {
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + hex.EncodeToString([]byte{sc.Flags})
+}
+
+// ParseTraceparent 解析 traceparent 头
+// 版本 00 必须正好 4 段，更高版本按前 4 段解析以保持向前兼容，版本 ff 无效
+func ParseTraceparent(value string) (SpanContext, error) {
+	sc := SpanContext{}
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return sc, ErrorInvalidTraceparent
+	}
+	version, err := decodeHex(parts[0], 1)
+	if err != nil || version[0] == 0xff || (version[0] == 0 && len(parts) != 4) {
+		return sc, ErrorInvalidTraceparent
+	}
+	traceID, err := decodeHex(parts[1], 16)
+	if err != nil {
+		return sc, ErrorInvalidTraceparent
+	}
+	spanID, err := decodeHex(parts[2], 8)
+	if err != nil {
+		return sc, ErrorInvalidTraceparent
+	}
+	flags, err := decodeHex(parts[3], 1)
+	if err != nil {
+		return sc, ErrorInvalidTraceparent
+	}
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Flags = flags[0]
+	sc.Remote = true
+	if !sc.IsValid() {
+		return SpanContext{}, ErrorInvalidTraceparent
+	}
+	return sc, nil
+}
+
+// decodeHex 只接受小写十六进制
+func decodeHex(s string, size int) ([]byte, error) {
+	if len(s) != size*2 || strings.ToLower(s) != s {
+		return nil, ErrorInvalidTraceparent
+	}
+	return hex.DecodeString(s)
+}
+
+// Extract 从请求头解析 traceparent 和 tracestate
+func Extract(header http.Header) (SpanContext, bool) {
+	sc, err := ParseTraceparent(header.Get(traceparentHeader))
+	if err != nil {
+		return SpanContext{}, false
+	}
+	// 多个 tracestate 头按逗号合并
+	state := strings.Join(header.Values(tracestateHeader), ",")
+	if len(state) <= maxTracestateLength {
+		sc.TraceState = state
+	}
+	return sc, true
+}
+
+// Inject 把 span 信息写入请求头，调用下游服务时使用
+func Inject(sc SpanContext, header http.Header) {
+	if !sc.IsValid() {
+		return
+	}
+	header.Set(traceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		header.Set(tracestateHeader, sc.TraceState)
+	}
+}
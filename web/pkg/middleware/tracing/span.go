@@ -0,0 +1,222 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"sync"
+	"time"
+)
+
+// SpanKind span 类型
+type SpanKind string
+
+const (
+	SpanKindServer   SpanKind = "server"
+	SpanKindClient   SpanKind = "client"
+	SpanKindInternal SpanKind = "internal"
+)
+
+// StatusCode span 状态
+type StatusCode string
+
+const (
+	StatusUnset StatusCode = "unset"
+	StatusOK    StatusCode = "ok"
+	StatusError StatusCode = "error"
+)
+
+// SpanData 结束后导出的 span 数据
+type SpanData struct {
+	TraceID       string         `json:"trace_id"`
+	SpanID        string         `json:"span_id"`
+	ParentSpanID  string         `json:"parent_span_id,omitempty"`
+	Name          string         `json:"name"`
+	Kind          SpanKind       `json:"kind"`
+	StartTime     time.Time      `json:"start_time"`
+	EndTime       time.Time      `json:"end_time"`
+	Attributes    map[string]any `json:"attributes,omitempty"`
+	StatusCode    StatusCode     `json:"status_code"`
+	StatusMessage string         `json:"status_message,omitempty"`
+	TraceState    string         `json:"trace_state,omitempty"`
+}
+
+// Span 一次操作的耗时记录，可以并发设置属性，End 只生效一次
+// nil Span 的方法都是空操作，没有开启追踪时调用方不用判断
+type Span struct {
+	tracer       *Tracer
+	spanContext  SpanContext
+	parentSpanID SpanID
+	name         string
+	kind         SpanKind
+	start        time.Time
+
+	mu            sync.Mutex
+	attributes    map[string]any
+	statusCode    StatusCode
+	statusMessage string
+	ended         bool
+}
+
+// SpanContext 返回 span 的传递信息，调用下游服务时通过 Inject 写入请求头
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.spanContext
+}
+
+// SetAttribute 设置属性
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attributes == nil {
+		s.attributes = make(map[string]any)
+	}
+	s.attributes[key] = value
+}
+
+// SetStatus 设置状态
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statusCode = code
+	s.statusMessage = message
+}
+
+// End 结束 span，采样的 span 交给导出器
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	attributes := make(map[string]any, len(s.attributes))
+	for k, v := range s.attributes {
+		attributes[k] = v
+	}
+	data := SpanData{
+		TraceID:       s.spanContext.TraceID.String(),
+		SpanID:        s.spanContext.SpanID.String(),
+		Name:          s.name,
+		Kind:          s.kind,
+		StartTime:     s.start,
+		EndTime:       time.Now(),
+		Attributes:    attributes,
+		StatusCode:    s.statusCode,
+		StatusMessage: s.statusMessage,
+		TraceState:    s.spanContext.TraceState,
+	}
+	s.mu.Unlock()
+
+	if s.parentSpanID.IsValid() {
+		data.ParentSpanID = s.parentSpanID.String()
+	}
+	if s.spanContext.IsSampled() {
+		s.tracer.export(data)
+	}
+}
+
+// StartChild 创建子 span
+func (s *Span) StartChild(name string, kind SpanKind) *Span {
+	if s == nil {
+		return nil
+	}
+	return s.tracer.Start(name, kind, s.spanContext)
+}
+
+// Exporter span 导出器
+type Exporter interface {
+	ExportSpan(span SpanData) error
+}
+
+// Tracer 创建 span
+type Tracer struct {
+	exporter Exporter
+	// Sampler 决定是否采样新的 trace，默认全部采样，有父 span 时跟随父 span
+	sampler func(traceID TraceID) bool
+	// ErrorHandler 导出失败时调用
+	errorHandler func(err error)
+}
+
+// TracerOption Tracer 可选配置
+type TracerOption func(t *Tracer)
+
+// WithSampler 设置新 trace 的采样函数
+func WithSampler(sampler func(traceID TraceID) bool) TracerOption {
+	return func(t *Tracer) {
+		t.sampler = sampler
+	}
+}
+
+// WithErrorHandler 设置导出失败的处理函数
+func WithErrorHandler(handler func(err error)) TracerOption {
+	return func(t *Tracer) {
+		t.errorHandler = handler
+	}
+}
+
+func NewTracer(exporter Exporter, opts ...TracerOption) *Tracer {
+	t := &Tracer{
+		exporter:     exporter,
+		sampler:      func(traceID TraceID) bool { return true },
+		errorHandler: func(err error) {},
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Start 创建 span，parent 无效时创建新的 trace
+func (t *Tracer) Start(name string, kind SpanKind, parent SpanContext) *Span {
+	span := &Span{
+		tracer:     t,
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		statusCode: StatusUnset,
+	}
+	if parent.IsValid() {
+		span.spanContext = SpanContext{TraceID: parent.TraceID, Flags: parent.Flags, TraceState: parent.TraceState}
+		span.parentSpanID = parent.SpanID
+	} else {
+		_, _ = rand.Read(span.spanContext.TraceID[:])
+		if t.sampler(span.spanContext.TraceID) {
+			span.spanContext.Flags = flagSampled
+		}
+	}
+	_, _ = rand.Read(span.spanContext.SpanID[:])
+	return span
+}
+
+func (t *Tracer) export(data SpanData) {
+	if t.exporter == nil {
+		return
+	}
+	if err := t.exporter.ExportSpan(data); err != nil {
+		t.errorHandler(err)
+	}
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpan 把 span 放入 context.Context，跨函数传递或者发起下游调用时使用
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// SpanFromContext 从 context.Context 取出 span，没有返回 nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
@@ -0,0 +1,61 @@
+package tracing
+
+import (
+	"net/http"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+)
+
+// spanKey 请求 span 保存在 Context.Keys 里的 key
+const spanKey = "github.com/2456868764/go-learning/web/pkg/middleware/tracing"
+
+// Middleware 为每个请求创建 server span，名字为 "方法 路由模式"
+// 请求头带有 traceparent 时延续上游 trace，响应头返回当前 span 的 traceparent
+func Middleware(tracer *Tracer) engine.HandlerFunc {
+	return func(c *engine.Context) {
+		parent, _ := Extract(c.R.Header)
+		route := c.FullPath()
+		name := c.Method + " " + route
+		if route == "" {
+			name = c.Method
+		}
+		span := tracer.Start(name, SpanKindServer, parent)
+		defer span.End()
+
+		span.SetAttribute("http.method", c.Method)
+		span.SetAttribute("http.target", c.R.URL.RequestURI())
+		if route != "" {
+			span.SetAttribute("http.route", route)
+		}
+		c.Set(spanKey, span)
+		c.R = c.R.WithContext(ContextWithSpan(c.R.Context(), span))
+		Inject(span.SpanContext(), c.W.Header())
+
+		c.Next()
+
+		status := c.ResponseStatus()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttribute("http.status_code", status)
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(StatusError, http.StatusText(status))
+		}
+	}
+}
+
+// SpanFromEngineContext 返回请求 span，没有使用 Middleware 返回 nil
+func SpanFromEngineContext(c *engine.Context) *Span {
+	v, _ := c.Get(spanKey)
+	span, _ := v.(*Span)
+	return span
+}
+
+// StartSpan 在请求 span 下创建子 span，没有请求 span 时返回 nil，nil Span 可以安全调用
+func StartSpan(c *engine.Context, name string) *Span {
+	parent := SpanFromEngineContext(c)
+	if parent == nil {
+		return nil
+	}
+	return parent.StartChild(name, SpanKindInternal)
+}
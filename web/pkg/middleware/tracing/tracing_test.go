@@ -0,0 +1,119 @@
+package tracing
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	sc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.Nil(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	assert.True(t, sc.IsSampled())
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sc.Traceparent())
+
+	// 高版本可以有更多字段
+	_, err = ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra")
+	assert.Nil(t, err)
+
+	invalid := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+	}
+	for _, value := range invalid {
+		_, err = ParseTraceparent(value)
+		assert.Equal(t, ErrorInvalidTraceparent, err, value)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	tracer := NewTracer(exporter)
+	e := engine.New()
+	e.Use(Middleware(tracer))
+	e.GET("/user/:id", func(c *engine.Context) {
+		child := StartSpan(c, "load user")
+		child.SetAttribute("user.id", c.PathParams["id"])
+		child.End()
+		c.StringOk("ok")
+	})
+	e.GET("/error", func(c *engine.Context) {
+		c.StringFormat(http.StatusInternalServerError, "error")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/user/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set("tracestate", "vendor=value")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+
+	spans := exporter.Spans()
+	assert.Equal(t, 2, len(spans))
+	child, server := spans[0], spans[1]
+	assert.Equal(t, "GET /user/:id", server.Name)
+	assert.Equal(t, SpanKindServer, server.Kind)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", server.ParentSpanID)
+	assert.Equal(t, "vendor=value", server.TraceState)
+	assert.Equal(t, 200, server.Attributes["http.status_code"])
+	assert.Equal(t, "load user", child.Name)
+	assert.Equal(t, server.SpanID, child.ParentSpanID)
+	assert.Equal(t, server.TraceID, child.TraceID)
+	assert.Equal(t, "42", child.Attributes["user.id"])
+
+	// 响应头返回当前 span
+	sc, err := ParseTraceparent(w.Header().Get("traceparent"))
+	assert.Nil(t, err)
+	assert.Equal(t, server.SpanID, sc.SpanID.String())
+
+	exporter.Reset()
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/error", nil))
+	spans = exporter.Spans()
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, StatusError, spans[0].StatusCode)
+	assert.Empty(t, spans[0].ParentSpanID)
+
+	// 上游没有采样的 trace 不导出
+	exporter.Reset()
+	req = httptest.NewRequest(http.MethodGet, "/user/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Empty(t, exporter.Spans())
+}
+
+func TestFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	exporter, err := NewFileExporter(path)
+	assert.Nil(t, err)
+	tracer := NewTracer(exporter)
+	root := tracer.Start("root", SpanKindInternal, SpanContext{})
+	root.StartChild("child", SpanKindClient).End()
+	root.End()
+	assert.Nil(t, exporter.Close())
+
+	file, err := os.Open(path)
+	assert.Nil(t, err)
+	defer file.Close()
+	var names []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		data := SpanData{}
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &data))
+		names = append(names, data.Name)
+	}
+	assert.Equal(t, []string{"child", "root"}, names)
+}
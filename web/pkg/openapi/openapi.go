@@ -0,0 +1,334 @@
+package openapi
+
+import (
+	_ "embed"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+)
+
+// MetaKey 路由文档注解保存在路由元数据里的 key
+const MetaKey = "openapi.annotation"
+
+// annotation 注册路由时声明的文档信息
+type annotation struct {
+	summary     string
+	description string
+	tags        []string
+	operationID string
+	deprecated  bool
+	hidden      bool
+	parameters  []param
+	request     any
+	responses   []response
+}
+
+// param 声明的参数，value 用于生成 schema
+type param struct {
+	Parameter
+	value any
+}
+
+type response struct {
+	code        int
+	body        any
+	description string
+}
+
+// annotate 修改路由上的注解，多个选项合并到同一个注解
+func annotate(f func(a *annotation)) engine.RouteOption {
+	return func(route *engine.Route) {
+		a, _ := route.Meta[MetaKey].(annotation)
+		f(&a)
+		engine.WithMeta(MetaKey, a)(route)
+	}
+}
+
+// Summary 操作简介
+func Summary(summary string) engine.RouteOption {
+	return annotate(func(a *annotation) { a.summary = summary })
+}
+
+// Description 操作详细说明
+func Description(description string) engine.RouteOption {
+	return annotate(func(a *annotation) { a.description = description })
+}
+
+// Tags 操作分组
+func Tags(tags ...string) engine.RouteOption {
+	return annotate(func(a *annotation) { a.tags = append(a.tags, tags...) })
+}
+
+// OperationID 操作唯一标识，默认使用路由名字
+func OperationID(id string) engine.RouteOption {
+	return annotate(func(a *annotation) { a.operationID = id })
+}
+
+// Deprecated 标记操作已经废弃
+func Deprecated() engine.RouteOption {
+	return annotate(func(a *annotation) { a.deprecated = true })
+}
+
+// Hidden 路由不出现在文档里
+func Hidden() engine.RouteOption {
+	return annotate(func(a *annotation) { a.hidden = true })
+}
+
+// Param 声明 query、header、cookie 参数，路径参数根据 pattern 自动生成，in 为 path 时覆盖同名的路径参数
+// v 用于生成参数 schema，比如 0 表示整数，nil 表示字符串
+func Param(in string, name string, v any, description string, required bool) engine.RouteOption {
+	return annotate(func(a *annotation) {
+		a.parameters = append(a.parameters, param{
+			Parameter: Parameter{
+				Name:        name,
+				In:          in,
+				Description: description,
+				Required:    required || in == "path",
+			},
+			value: v,
+		})
+	})
+}
+
+// Accepts 请求体类型，比如 Accepts(CreateUserRequest{})，按 application/json 生成 schema
+func Accepts(body any) engine.RouteOption {
+	return annotate(func(a *annotation) { a.request = body })
+}
+
+// Returns 响应状态码对应的响应体类型，body 为 nil 表示没有响应体
+func Returns(code int, body any, description string) engine.RouteOption {
+	return annotate(func(a *annotation) {
+		a.responses = append(a.responses, response{code: code, body: body, description: description})
+	})
+}
+
+// Options 文档配置
+type Options struct {
+	Info    Info
+	Servers []Server
+	// SpecPath 文档 JSON 路由，默认 /openapi.json
+	SpecPath string
+	// DocsPath 文档页面路由，默认 /docs
+	DocsPath string
+}
+
+// Generate 根据引擎已经注册的路由生成文档
+func Generate(e *engine.Engine, info Info) *Document {
+	if info.Title == "" {
+		info.Title = "API"
+	}
+	if info.Version == "" {
+		info.Version = "1.0.0"
+	}
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]*PathItem),
+	}
+	registry := newSchemaRegistry()
+	tags := make(map[string]bool)
+
+	routes := e.Routes()
+	// Any 注册的多个方法共用名字和注解，operationId 重复时加上方法前缀
+	operationIDs := make(map[string]int)
+	for _, route := range routes {
+		if a, _ := route.Meta[MetaKey].(annotation); !a.hidden {
+			operationIDs[operationID(route, a)]++
+		}
+	}
+
+	for _, route := range routes {
+		a, _ := route.Meta[MetaKey].(annotation)
+		if a.hidden {
+			continue
+		}
+		path, parameters := convertPattern(route.Pattern)
+		operation := &Operation{
+			Tags:        a.tags,
+			Summary:     a.summary,
+			Description: a.description,
+			OperationID: operationID(route, a),
+			Deprecated:  a.deprecated,
+			Responses:   make(map[string]*Response),
+		}
+		if id := operation.OperationID; id != "" && operationIDs[id] > 1 {
+			operation.OperationID = strings.ToLower(route.Method) + strings.ToUpper(id[:1]) + id[1:]
+		}
+		operation.Parameters = mergeParameters(parameters, a.parameters, registry)
+		if a.request != nil {
+			operation.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]MediaType{"application/json": {Schema: registry.schemaOf(a.request)}},
+			}
+		}
+		for _, resp := range a.responses {
+			description := resp.description
+			if description == "" {
+				description = http.StatusText(resp.code)
+			}
+			r := &Response{Description: description}
+			if resp.body != nil {
+				r.Content = map[string]MediaType{"application/json": {Schema: registry.schemaOf(resp.body)}}
+			}
+			operation.Responses[strconv.Itoa(resp.code)] = r
+		}
+		if len(operation.Responses) == 0 {
+			operation.Responses["200"] = &Response{Description: http.StatusText(http.StatusOK)}
+		}
+		for _, tag := range a.tags {
+			tags[tag] = true
+		}
+
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = &PathItem{}
+		}
+		// 挂载的 http.Handler 等不支持的方法不输出
+		if item.set(route.Method, operation) {
+			doc.Paths[path] = item
+		}
+	}
+
+	if len(registry.schemas) > 0 {
+		doc.Components = &Components{Schemas: registry.schemas}
+	}
+	for tag := range tags {
+		doc.Tags = append(doc.Tags, Tag{Name: tag})
+	}
+	sort.Slice(doc.Tags, func(i, j int) bool { return doc.Tags[i].Name < doc.Tags[j].Name })
+	return doc
+}
+
+// operationID 注解没有设置时使用路由名字
+func operationID(route engine.RouteInfo, a annotation) string {
+	if a.operationID != "" {
+		return a.operationID
+	}
+	return route.Name
+}
+
+func (p *PathItem) set(method string, operation *Operation) bool {
+	switch method {
+	case http.MethodGet:
+		p.Get = operation
+	case http.MethodPost:
+		p.Post = operation
+	case http.MethodPut:
+		p.Put = operation
+	case http.MethodDelete:
+		p.Delete = operation
+	case http.MethodPatch:
+		p.Patch = operation
+	default:
+		return false
+	}
+	return true
+}
+
+// convertPattern 把路由 pattern 转换成 OpenAPI 路径，:id 和 :id<int> 转换成 {id}，* 转换成 {wildcard}
+func convertPattern(pattern string) (string, []Parameter) {
+	segments := strings.Split(pattern, "/")
+	parameters := make([]Parameter, 0)
+	for i, segment := range segments {
+		var name, paramType string
+		switch {
+		case segment == "*":
+			name = "wildcard"
+		case strings.HasPrefix(segment, ":"):
+			name, paramType = engine.ParseParam(segment)
+		default:
+			continue
+		}
+		segments[i] = "{" + name + "}"
+		parameters = append(parameters, Parameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   paramTypeSchema(paramType),
+		})
+	}
+	return strings.Join(segments, "/"), parameters
+}
+
+// paramTypeSchema 根据路径参数类型约束生成 schema，自定义类型按字符串处理
+func paramTypeSchema(paramType string) *Schema {
+	switch paramType {
+	case "int":
+		return &Schema{Type: "integer", Format: "int64"}
+	case "uint":
+		return &Schema{Type: "integer", Format: "int64", Minimum: new(float64)}
+	case "uuid":
+		return &Schema{Type: "string", Format: "uuid"}
+	case "alpha":
+		return &Schema{Type: "string", Pattern: "^[A-Za-z]+$"}
+	case "alnum":
+		return &Schema{Type: "string", Pattern: "^[A-Za-z0-9]+$"}
+	default:
+		return &Schema{Type: "string"}
+	}
+}
+
+// mergeParameters 合并自动生成的路径参数和注解声明的参数，同名同位置的以注解为准
+func mergeParameters(parameters []Parameter, declared []param, registry *schemaRegistry) []Parameter {
+	for _, d := range declared {
+		p := d.Parameter
+		p.Schema = registry.schemaOf(d.value)
+		if p.Schema == nil {
+			p.Schema = &Schema{Type: "string"}
+		}
+		replaced := false
+		for i := range parameters {
+			if parameters[i].Name == p.Name && parameters[i].In == p.In {
+				parameters[i] = p
+				replaced = true
+			}
+		}
+		if !replaced {
+			parameters = append(parameters, p)
+		}
+	}
+	return parameters
+}
+
+// Register 注册文档 JSON 和文档页面路由，两个路由本身不出现在文档里
+// 文档在每次请求时根据当前路由生成，之后注册的路由也会出现在文档里
+func Register(e *engine.Engine, options Options) {
+	if options.SpecPath == "" {
+		options.SpecPath = "/openapi.json"
+	}
+	if options.DocsPath == "" {
+		options.DocsPath = "/docs"
+	}
+	e.GET(options.SpecPath, SpecHandler(e, options.Info, options.Servers...), Hidden())
+	e.GET(options.DocsPath, DocsHandler(options.Info.Title, options.SpecPath), Hidden())
+}
+
+// SpecHandler 返回文档 JSON
+func SpecHandler(e *engine.Engine, info Info, servers ...Server) engine.HandlerFunc {
+	return func(c *engine.Context) {
+		doc := Generate(e, info)
+		doc.Servers = servers
+		_ = c.OKJson(doc)
+	}
+}
+
+//go:embed docs.html
+var docsPage string
+
+var docsTemplate = template.Must(template.New("docs").Parse(docsPage))
+
+// DocsHandler 返回文档页面，页面加载 specURL 的文档 JSON 渲染操作列表，不依赖外部资源
+func DocsHandler(title string, specURL string) engine.HandlerFunc {
+	if title == "" {
+		title = "API"
+	}
+	return func(c *engine.Context) {
+		c.SetHeader("Content-Type", "text/html; charset=utf-8")
+		c.Status(http.StatusOK)
+		_ = docsTemplate.Execute(c.W, map[string]string{"Title": title, "SpecURL": specURL})
+	}
+}
@@ -0,0 +1,144 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+type Address struct {
+	City string `json:"city"`
+}
+
+type User struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name" description:"user name"`
+	Email     string    `json:"email,omitempty"`
+	Age       *int      `json:"age"`
+	Tags      []string  `json:"tags,omitempty"`
+	Address   Address   `json:"address"`
+	Friends   []*User   `json:"friends,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Secret    string    `json:"-"`
+	internal  string
+}
+
+type CreateUserRequest struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type ErrorResponse struct {
+	Message string `json:"message"`
+}
+
+func TestGenerate(t *testing.T) {
+	e := engine.New()
+	handler := func(c *engine.Context) {}
+	e.GET("/user/:id", handler,
+		engine.WithName("getUser"),
+		Summary("get user"),
+		Tags("user"),
+		Param("path", "id", int64(0), "user id", true),
+		Param("query", "fields", nil, "fields to return", false),
+		Returns(http.StatusOK, User{}, ""),
+		Returns(http.StatusNotFound, ErrorResponse{}, "user not found"),
+	)
+	e.POST("/user", handler,
+		Summary("create user"),
+		Tags("user"),
+		Accepts(CreateUserRequest{}),
+		Returns(http.StatusCreated, &User{}, ""),
+	)
+	e.GET("/static/*", handler, Deprecated())
+	e.GET("/internal", handler, Hidden())
+
+	doc := Generate(e, Info{Title: "test"})
+	assert.Equal(t, Version, doc.OpenAPI)
+	assert.Equal(t, "1.0.0", doc.Info.Version)
+	assert.Equal(t, 3, len(doc.Paths))
+	assert.NotContains(t, doc.Paths, "/internal")
+	assert.Equal(t, []Tag{{Name: "user"}}, doc.Tags)
+
+	getUser := doc.Paths["/user/{id}"].Get
+	assert.NotNil(t, getUser)
+	assert.Equal(t, "getUser", getUser.OperationID)
+	assert.Equal(t, "get user", getUser.Summary)
+	assert.Equal(t, []Parameter{
+		{Name: "id", In: "path", Description: "user id", Required: true, Schema: &Schema{Type: "integer", Format: "int64"}},
+		{Name: "fields", In: "query", Description: "fields to return", Schema: &Schema{Type: "string"}},
+	}, getUser.Parameters)
+	assert.Equal(t, "OK", getUser.Responses["200"].Description)
+	assert.Equal(t, "#/components/schemas/User", getUser.Responses["200"].Content["application/json"].Schema.Ref)
+	assert.Equal(t, "user not found", getUser.Responses["404"].Description)
+
+	createUser := doc.Paths["/user"].Post
+	assert.Equal(t, "#/components/schemas/CreateUserRequest", createUser.RequestBody.Content["application/json"].Schema.Ref)
+	assert.Contains(t, createUser.Responses, "201")
+
+	static := doc.Paths["/static/{wildcard}"].Get
+	assert.True(t, static.Deprecated)
+	assert.Equal(t, "wildcard", static.Parameters[0].Name)
+	assert.Equal(t, "OK", static.Responses["200"].Description)
+
+	user := doc.Components.Schemas["User"]
+	assert.Equal(t, "object", user.Type)
+	assert.Equal(t, []string{"id", "name", "address", "created_at"}, user.Required)
+	assert.Equal(t, &Schema{Type: "integer", Format: "int64"}, user.Properties["id"])
+	assert.Equal(t, "user name", user.Properties["name"].Description)
+	assert.Equal(t, &Schema{Type: "integer", Format: "int32", Nullable: true}, user.Properties["age"])
+	assert.Equal(t, &Schema{Type: "array", Items: &Schema{Type: "string"}}, user.Properties["tags"])
+	assert.Equal(t, "#/components/schemas/Address", user.Properties["address"].Ref)
+	assert.Equal(t, "#/components/schemas/User", user.Properties["friends"].Items.Ref)
+	assert.Equal(t, &Schema{Type: "string", Format: "date-time"}, user.Properties["created_at"])
+	assert.NotContains(t, user.Properties, "Secret")
+	assert.NotContains(t, user.Properties, "internal")
+	assert.Contains(t, doc.Components.Schemas, "Address")
+	assert.Contains(t, doc.Components.Schemas, "ErrorResponse")
+}
+
+func TestGenerate_UniqueOperationID(t *testing.T) {
+	e := engine.New()
+	handler := func(c *engine.Context) {}
+	assert.Nil(t, e.Any("/user/:id", handler, engine.WithName("user")))
+	assert.Nil(t, e.Any("/order", handler, OperationID("order")))
+	e.GET("/health", handler, engine.WithName("health"))
+
+	doc := Generate(e, Info{})
+	user := doc.Paths["/user/{id}"]
+	assert.Equal(t, "getUser", user.Get.OperationID)
+	assert.Equal(t, "postUser", user.Post.OperationID)
+	assert.Equal(t, "deleteUser", user.Delete.OperationID)
+	assert.Equal(t, "patchOrder", doc.Paths["/order"].Patch.OperationID)
+	// 只有一个方法时不加前缀
+	assert.Equal(t, "health", doc.Paths["/health"].Get.OperationID)
+}
+
+func TestRegister(t *testing.T) {
+	e := engine.New()
+	Register(e, Options{Info: Info{Title: "httpbin", Version: "3.0"}})
+	e.GET("/headers", func(c *engine.Context) {}, Summary("headers"))
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	doc := map[string]any{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, "3.0.3", doc["openapi"])
+	paths := doc["paths"].(map[string]any)
+	assert.Equal(t, 1, len(paths))
+	assert.Equal(t, "headers", paths["/headers"].(map[string]any)["get"].(map[string]any)["summary"])
+
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.True(t, strings.Contains(w.Body.String(), `var specURL = "/openapi.json";`))
+	assert.True(t, strings.Contains(w.Body.String(), "<title>httpbin</title>"))
+}
@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"hash/fnv"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+// Balancer 负载均衡策略
+type Balancer interface {
+	// Pick 从 upstreams 中选择一个，upstreams 已经去掉不可用的上游，不为空
+	Pick(r *http.Request, upstreams []*Upstream) *Upstream
+}
+
+type roundRobin struct {
+	next atomic.Uint64
+}
+
+// RoundRobin 轮询
+func RoundRobin() Balancer {
+	return &roundRobin{}
+}
+
+func (b *roundRobin) Pick(_ *http.Request, upstreams []*Upstream) *Upstream {
+	n := b.next.Add(1) - 1
+	return upstreams[n%uint64(len(upstreams))]
+}
+
+type leastConnections struct{}
+
+// LeastConnections 选择正在处理请求最少的上游，相同时选择靠前的
+func LeastConnections() Balancer {
+	return leastConnections{}
+}
+
+func (leastConnections) Pick(_ *http.Request, upstreams []*Upstream) *Upstream {
+	picked := upstreams[0]
+	for _, u := range upstreams[1:] {
+		if u.Active() < picked.Active() {
+			picked = u
+		}
+	}
+	return picked
+}
+
+type consistentHash struct {
+	key func(r *http.Request) string
+}
+
+// ConsistentHash 一致性哈希，同一个 key 总是转发到同一个上游，适合有本地缓存或者会话的上游
+// 使用 rendezvous hashing，上游增减时只有原来落在该上游的 key 会迁移
+// key 为 nil 时使用客户端 IP
+func ConsistentHash(key func(r *http.Request) string) Balancer {
+	if key == nil {
+		key = clientIP
+	}
+	return &consistentHash{key: key}
+}
+
+func (b *consistentHash) Pick(r *http.Request, upstreams []*Upstream) *Upstream {
+	key := b.key(r)
+	var picked *Upstream
+	var best uint64
+	for _, u := range upstreams {
+		h := fnv.New64a()
+		h.Write([]byte(u.URL.String()))
+		h.Write([]byte{0})
+		h.Write([]byte(key))
+		if score := mix(h.Sum64()); picked == nil || score > best {
+			picked, best = u, score
+		}
+	}
+	return picked
+}
+
+// mix 打散 fnv 结果的高位，key 只有末尾不同时分布更均匀
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// HeaderKey 使用请求头作为一致性哈希 key，比如 HeaderKey("X-User-ID")
+func HeaderKey(name string) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,189 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+var ErrorNoUpstream = errors.New("proxy: no upstream")
+
+// Upstream 一个上游服务
+type Upstream struct {
+	URL *url.URL
+	// 正在处理的请求数
+	active atomic.Int64
+	// 主动健康检查结果，默认健康
+	unhealthy atomic.Bool
+	// 连续失败次数，用于被动摘除
+	fails atomic.Int32
+	// 被动摘除截止时间，UnixNano
+	ejectedUntil atomic.Int64
+}
+
+// Active 返回正在处理的请求数
+func (u *Upstream) Active() int64 {
+	return u.active.Load()
+}
+
+// Available 上游是否可用，主动健康检查失败或者被动摘除期间不可用
+func (u *Upstream) Available() bool {
+	return !u.unhealthy.Load() && time.Now().UnixNano() >= u.ejectedUntil.Load()
+}
+
+// HealthCheck 主动健康检查配置
+type HealthCheck struct {
+	// Path 检查路径，比如 /healthz，返回 2xx 或者 3xx 表示健康
+	Path string
+	// Interval 检查间隔，默认 10s
+	Interval time.Duration
+	// Timeout 单次检查超时时间，默认 2s
+	Timeout time.Duration
+	// Client 检查使用的 http.Client，默认 http.DefaultClient
+	Client *http.Client
+}
+
+// PoolOptions 上游池配置
+type PoolOptions struct {
+	// Balancer 负载均衡策略，默认 RoundRobin
+	Balancer Balancer
+	// HealthCheck 主动健康检查，Path 为空表示不检查
+	HealthCheck HealthCheck
+	// MaxFails 连续失败多少次后摘除上游，连接失败和 502/503/504 响应算失败，默认 3，小于 0 表示不摘除
+	MaxFails int
+	// EjectDuration 摘除时长，到期后重新参与负载均衡，默认 30s
+	EjectDuration time.Duration
+}
+
+// Pool 上游池
+type Pool struct {
+	upstreams []*Upstream
+	options   PoolOptions
+}
+
+// NewPool 创建上游池，targets 是上游地址，比如 http://10.0.0.1:8080
+func NewPool(targets []string, options PoolOptions) (*Pool, error) {
+	if len(targets) == 0 {
+		return nil, ErrorNoUpstream
+	}
+	if options.Balancer == nil {
+		options.Balancer = RoundRobin()
+	}
+	if options.MaxFails == 0 {
+		options.MaxFails = 3
+	}
+	if options.EjectDuration == 0 {
+		options.EjectDuration = 30 * time.Second
+	}
+	if options.HealthCheck.Interval == 0 {
+		options.HealthCheck.Interval = 10 * time.Second
+	}
+	if options.HealthCheck.Timeout == 0 {
+		options.HealthCheck.Timeout = 2 * time.Second
+	}
+	if options.HealthCheck.Client == nil {
+		options.HealthCheck.Client = http.DefaultClient
+	}
+	pool := &Pool{options: options}
+	for _, target := range targets {
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, errors.New("proxy: invalid upstream " + target)
+		}
+		pool.upstreams = append(pool.upstreams, &Upstream{URL: u})
+	}
+	return pool, nil
+}
+
+// Upstreams 返回所有上游
+func (p *Pool) Upstreams() []*Upstream {
+	return p.upstreams
+}
+
+// pick 在可用并且没有尝试过的上游中选择一个，都不可用时返回 nil
+func (p *Pool) pick(r *http.Request, tried []*Upstream) *Upstream {
+	candidates := make([]*Upstream, 0, len(p.upstreams))
+	for _, u := range p.upstreams {
+		if u.Available() && !contains(tried, u) {
+			candidates = append(candidates, u)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	return p.options.Balancer.Pick(r, candidates)
+}
+
+// success 请求成功，清零连续失败次数
+func (p *Pool) success(u *Upstream) {
+	u.fails.Store(0)
+}
+
+// failure 请求失败，连续失败达到 MaxFails 时摘除
+func (p *Pool) failure(u *Upstream) {
+	if p.options.MaxFails < 0 {
+		return
+	}
+	if int(u.fails.Add(1)) >= p.options.MaxFails {
+		u.fails.Store(0)
+		u.ejectedUntil.Store(time.Now().Add(p.options.EjectDuration).UnixNano())
+	}
+}
+
+// StartHealthCheck 启动主动健康检查，立即检查一次，之后每隔 Interval 检查，ctx 结束时停止
+func (p *Pool) StartHealthCheck(ctx context.Context) {
+	if p.options.HealthCheck.Path == "" {
+		return
+	}
+	p.checkAll(ctx)
+	go func() {
+		ticker := time.NewTicker(p.options.HealthCheck.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.checkAll(ctx)
+			}
+		}
+	}()
+}
+
+func (p *Pool) checkAll(ctx context.Context) {
+	for _, u := range p.upstreams {
+		u.unhealthy.Store(!p.check(ctx, u))
+	}
+}
+
+func (p *Pool) check(ctx context.Context, u *Upstream) bool {
+	ctx, cancel := context.WithTimeout(ctx, p.options.HealthCheck.Timeout)
+	defer cancel()
+	target := *u.URL
+	target.Path = singleJoiningSlash(u.URL.Path, p.options.HealthCheck.Path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return false
+	}
+	resp, err := p.options.HealthCheck.Client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode >= 200 && resp.StatusCode < 400
+}
+
+func contains(upstreams []*Upstream, u *Upstream) bool {
+	for _, item := range upstreams {
+		if item == u {
+			return true
+		}
+	}
+	return false
+}
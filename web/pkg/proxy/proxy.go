@@ -0,0 +1,191 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+)
+
+// Options 反向代理配置
+type Options struct {
+	// StripPrefix 转发前去掉的路径前缀，比如 /api/users 去掉 /api 后转发 /users
+	// 通过 Engine.Mount 挂载时 Mount 已经去掉前缀，不需要设置
+	StripPrefix string
+	// PreserveHost 保留请求的 Host 头，默认改成上游的 Host
+	PreserveHost bool
+	// SetRequestHeaders 转发前设置的请求头
+	SetRequestHeaders map[string]string
+	// RemoveRequestHeaders 转发前删除的请求头，比如 Cookie
+	RemoveRequestHeaders []string
+	// SetResponseHeaders 返回前设置的响应头
+	SetResponseHeaders map[string]string
+	// RemoveResponseHeaders 返回前删除的响应头，比如 Server
+	RemoveResponseHeaders []string
+	// Retries 连接上游失败时换一个上游重试的次数，只重试没有请求体的幂等方法
+	Retries int
+	// Transport 转发使用的 http.RoundTripper，默认 http.DefaultTransport
+	Transport http.RoundTripper
+}
+
+// Proxy 把请求转发到上游池，实现了 http.Handler，可以通过 Engine.Mount 挂载，也可以用 Handle 注册为路由
+type Proxy struct {
+	pool    *Pool
+	options Options
+	proxies map[*Upstream]*httputil.ReverseProxy
+}
+
+// attempt 一次转发的结果，通过 request context 传给 ErrorHandler
+type attempt struct {
+	err error
+}
+
+type attemptKey struct{}
+
+func New(pool *Pool, options Options) *Proxy {
+	p := &Proxy{
+		pool:    pool,
+		options: options,
+		proxies: make(map[*Upstream]*httputil.ReverseProxy, len(pool.upstreams)),
+	}
+	for _, u := range pool.upstreams {
+		p.proxies[u] = p.newReverseProxy(u)
+	}
+	return p
+}
+
+// Handle 反向代理 HandlerFunc，比如 e.GET("/api/users/:id", p.Handle)
+func (p *Proxy) Handle(c *engine.Context) {
+	p.ServeHTTP(c.W, c.R)
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	attempts := 1
+	if retryable(r) {
+		attempts += p.options.Retries
+	}
+	var tried []*Upstream
+	for i := 0; i < attempts; i++ {
+		u := p.pool.pick(r, tried)
+		if u == nil {
+			break
+		}
+		tried = append(tried, u)
+		a := p.serveUpstream(u, w, r)
+		if a.err == nil {
+			return
+		}
+		p.pool.failure(u)
+		// 客户端已经断开，不再重试
+		if r.Context().Err() != nil {
+			break
+		}
+	}
+	if len(tried) == 0 {
+		http.Error(w, "Service Unavailable: no available upstream", http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+}
+
+// serveUpstream 转发到上游，ReverseProxy 在客户端断开或者复制响应体失败时
+// 会 panic(http.ErrAbortHandler)，连接数要在 defer 里减掉
+func (p *Proxy) serveUpstream(u *Upstream, w http.ResponseWriter, r *http.Request) *attempt {
+	a := &attempt{}
+	u.active.Add(1)
+	defer u.active.Add(-1)
+	p.proxies[u].ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), attemptKey{}, a)))
+	return a
+}
+
+func (p *Proxy) newReverseProxy(u *Upstream) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			p.rewriteRequest(req, u)
+		},
+		Transport: p.options.Transport,
+		ModifyResponse: func(resp *http.Response) error {
+			switch resp.StatusCode {
+			case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+				p.pool.failure(u)
+			default:
+				p.pool.success(u)
+			}
+			for _, name := range p.options.RemoveResponseHeaders {
+				resp.Header.Del(name)
+			}
+			for name, value := range p.options.SetResponseHeaders {
+				resp.Header.Set(name, value)
+			}
+			return nil
+		},
+		// 连接失败时不写响应，由 ServeHTTP 决定重试还是返回 502
+		ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+			if a, ok := req.Context().Value(attemptKey{}).(*attempt); ok {
+				a.err = err
+			}
+		},
+	}
+}
+
+func (p *Proxy) rewriteRequest(req *http.Request, u *Upstream) {
+	path, rawPath := req.URL.Path, req.URL.RawPath
+	if prefix := strings.TrimRight(p.options.StripPrefix, "/"); prefix != "" {
+		path = strings.TrimPrefix(path, prefix)
+		rawPath = strings.TrimPrefix(rawPath, prefix)
+	}
+	req.URL.Scheme = u.URL.Scheme
+	req.URL.Host = u.URL.Host
+	req.URL.Path = singleJoiningSlash(u.URL.Path, path)
+	if rawPath != "" {
+		req.URL.RawPath = singleJoiningSlash(u.URL.EscapedPath(), rawPath)
+	}
+	if u.URL.RawQuery == "" || req.URL.RawQuery == "" {
+		req.URL.RawQuery = u.URL.RawQuery + req.URL.RawQuery
+	} else {
+		req.URL.RawQuery = u.URL.RawQuery + "&" + req.URL.RawQuery
+	}
+
+	req.Header.Set("X-Forwarded-Host", req.Host)
+	if req.TLS != nil {
+		req.Header.Set("X-Forwarded-Proto", "https")
+	} else {
+		req.Header.Set("X-Forwarded-Proto", "http")
+	}
+	if !p.options.PreserveHost {
+		req.Host = ""
+	}
+	for _, name := range p.options.RemoveRequestHeaders {
+		req.Header.Del(name)
+	}
+	for name, value := range p.options.SetRequestHeaders {
+		req.Header.Set(name, value)
+	}
+	// 和 httputil.NewSingleHostReverseProxy 一样，不使用 Go 默认的 User-Agent
+	if _, ok := req.Header["User-Agent"]; !ok {
+		req.Header.Set("User-Agent", "")
+	}
+}
+
+// retryable 没有请求体的幂等方法可以重试
+func retryable(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0
+	}
+	return false
+}
+
+func singleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
@@ -0,0 +1,250 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+)
+
+// newUpstream 返回名字和请求信息的上游
+func newUpstream(t *testing.T, name string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "upstream")
+		w.Header().Set("X-Upstream", name)
+		fmt.Fprintf(w, "%s %s %s host=%s fwd=%s token=%s cookie=%s",
+			name, r.Method, r.URL.RequestURI(), r.Host, r.Header.Get("X-Forwarded-Host"),
+			r.Header.Get("X-Gateway-Token"), r.Header.Get("Cookie"))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// deadUpstream 返回一个已经关闭的上游地址，连接会失败
+func deadUpstream() string {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	return server.URL
+}
+
+func get(handler http.Handler, path string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestProxy_Rewrite(t *testing.T) {
+	a := newUpstream(t, "a")
+	pool, err := NewPool([]string{a.URL + "/v1?source=gw"}, PoolOptions{})
+	assert.Nil(t, err)
+	p := New(pool, Options{
+		StripPrefix:           "/api",
+		SetRequestHeaders:     map[string]string{"X-Gateway-Token": "secret"},
+		RemoveRequestHeaders:  []string{"Cookie"},
+		SetResponseHeaders:    map[string]string{"X-Gateway": "web"},
+		RemoveResponseHeaders: []string{"Server"},
+	})
+
+	e := engine.New()
+	e.GET("/api/users/:id", p.Handle)
+	w := get(e, "/api/users/1?name=jun", map[string]string{"Cookie": "session=1"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "a GET /v1/users/1?source=gw&name=jun host="+strings.TrimPrefix(a.URL, "http://")+
+		" fwd=example.com token=secret cookie=", w.Body.String())
+	assert.Equal(t, "web", w.Header().Get("X-Gateway"))
+	assert.Equal(t, "", w.Header().Get("Server"))
+
+	// Mount 已经去掉前缀
+	p = New(pool, Options{PreserveHost: true})
+	assert.Nil(t, e.Mount("/gateway", p))
+	w = get(e, "/gateway/orders", nil)
+	assert.Equal(t, "a GET /v1/orders?source=gw host=example.com fwd=example.com token= cookie=", w.Body.String())
+}
+
+func TestBalancer(t *testing.T) {
+	upstreams := []*Upstream{}
+	for _, name := range []string{"a", "b", "c"} {
+		pool, _ := NewPool([]string{"http://" + name}, PoolOptions{})
+		upstreams = append(upstreams, pool.upstreams[0])
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	rr := RoundRobin()
+	var picked []string
+	for i := 0; i < 4; i++ {
+		picked = append(picked, rr.Pick(req, upstreams).URL.Host)
+	}
+	assert.Equal(t, []string{"a", "b", "c", "a"}, picked)
+
+	upstreams[0].active.Store(2)
+	upstreams[1].active.Store(1)
+	upstreams[2].active.Store(3)
+	assert.Equal(t, "b", LeastConnections().Pick(req, upstreams).URL.Host)
+
+	// 同一个 key 总是选同一个上游，key 大致均匀分布
+	ch := ConsistentHash(HeaderKey("X-User-ID"))
+	counts := map[string]int{}
+	for i := 0; i < 300; i++ {
+		req.Header.Set("X-User-ID", fmt.Sprint(i))
+		u := ch.Pick(req, upstreams)
+		assert.Equal(t, u, ch.Pick(req, upstreams))
+		counts[u.URL.Host]++
+		// 摘除另一个上游，key 仍然落在原来的上游
+		var remaining []*Upstream
+		removed := false
+		for _, o := range upstreams {
+			if o != u && !removed {
+				removed = true
+				continue
+			}
+			remaining = append(remaining, o)
+		}
+		assert.Equal(t, u, ch.Pick(req, remaining))
+	}
+	for _, host := range []string{"a", "b", "c"} {
+		assert.True(t, counts[host] > 50, "%s got %d keys", host, counts[host])
+	}
+}
+
+func TestProxy_RetryAndPassiveEjection(t *testing.T) {
+	a := newUpstream(t, "a")
+	dead := deadUpstream()
+	pool, err := NewPool([]string{dead, a.URL}, PoolOptions{MaxFails: 2, EjectDuration: time.Hour})
+	assert.Nil(t, err)
+	p := New(pool, Options{Retries: 1})
+
+	// 第一次选中 dead，连接失败后重试 a
+	w := get(p, "/", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "a", w.Header().Get("X-Upstream"))
+	assert.True(t, pool.upstreams[0].Available())
+
+	// POST 不重试，轮询选中 dead 时直接返回 502
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}")))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+
+	// 连续失败 2 次后摘除，之后都转发到 a
+	assert.False(t, pool.upstreams[0].Available())
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "a", get(p, "/", nil).Header().Get("X-Upstream"))
+	}
+
+	// 没有可用上游
+	pool, _ = NewPool([]string{dead}, PoolOptions{MaxFails: 1})
+	p = New(pool, Options{})
+	assert.Equal(t, http.StatusBadGateway, get(p, "/", nil).Code)
+	assert.Equal(t, http.StatusServiceUnavailable, get(p, "/", nil).Code)
+}
+
+func TestPool_HealthCheck(t *testing.T) {
+	var healthy atomic.Bool
+	healthy.Store(true)
+	b := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" && !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("X-Upstream", "b")
+	}))
+	defer b.Close()
+	a := newUpstream(t, "a")
+
+	pool, err := NewPool([]string{a.URL, b.URL, deadUpstream()}, PoolOptions{
+		HealthCheck: HealthCheck{Path: "/healthz", Interval: 10 * time.Millisecond},
+	})
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool.StartHealthCheck(ctx)
+	// 启动时已经检查一次，关闭的上游不可用
+	assert.True(t, pool.upstreams[1].Available())
+	assert.False(t, pool.upstreams[2].Available())
+
+	healthy.Store(false)
+	assert.Eventually(t, func() bool {
+		return !pool.upstreams[1].Available()
+	}, time.Second, 5*time.Millisecond)
+	p := New(pool, Options{})
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "a", get(p, "/", nil).Header().Get("X-Upstream"))
+	}
+
+	healthy.Store(true)
+	assert.Eventually(t, func() bool {
+		return pool.upstreams[1].Available()
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestProxy_LeastConnectionsConcurrent(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Header().Set("X-Upstream", "slow")
+	}))
+	defer slow.Close()
+	fast := newUpstream(t, "fast")
+
+	pool, err := NewPool([]string{slow.URL, fast.URL}, PoolOptions{Balancer: LeastConnections()})
+	assert.Nil(t, err)
+	p := New(pool, Options{})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.Equal(t, "slow", get(p, "/", nil).Header().Get("X-Upstream"))
+	}()
+	assert.Eventually(t, func() bool {
+		return pool.upstreams[0].Active() == 1
+	}, time.Second, time.Millisecond)
+	// slow 有一个请求在处理，新请求转发到 fast
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "fast", get(p, "/", nil).Header().Get("X-Upstream"))
+	}
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int64(0), pool.upstreams[0].Active())
+}
+
+func TestNewPool(t *testing.T) {
+	_, err := NewPool(nil, PoolOptions{})
+	assert.Equal(t, ErrorNoUpstream, err)
+	_, err = NewPool([]string{"localhost:8080"}, PoolOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestProxy_AbortReleasesConnection(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		// 响应体没写完就断开，ReverseProxy 复制响应体失败后 panic
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer upstream.Close()
+
+	pool, err := NewPool([]string{upstream.URL}, PoolOptions{Balancer: LeastConnections()})
+	assert.Nil(t, err)
+	p := New(pool, Options{})
+	// ReverseProxy 只在 http.Server 里 panic，请求带上 ServerContextKey 模拟
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), http.ServerContextKey, &http.Server{}))
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		p.ServeHTTP(httptest.NewRecorder(), req)
+	})
+	assert.Equal(t, int64(0), pool.upstreams[0].Active())
+}
@@ -0,0 +1,270 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrorCircuitOpen 熔断器打开，请求没有发出
+	ErrorCircuitOpen = errors.New("resilience: circuit breaker is open")
+	// ErrorTooManyRequests 熔断器半开，试探请求数已满
+	ErrorTooManyRequests = errors.New("resilience: too many requests in half-open state")
+	// errorPanic Execute 的 fn panic 时记录的错误
+	errorPanic = errors.New("resilience: panic in breaker execution")
+)
+
+// State 熔断器状态
+type State int
+
+const (
+	// StateClosed 正常放行，统计失败率和慢调用比例
+	StateClosed State = iota
+	// StateOpen 拒绝所有请求，OpenTimeout 之后进入半开
+	StateOpen
+	// StateHalfOpen 放行少量试探请求，全部成功后关闭，有失败重新打开
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerOptions 熔断器配置
+type BreakerOptions struct {
+	// Name 熔断器名字，用于 OnStateChange 和指标
+	Name string
+	// Window 滚动统计窗口，默认 10s
+	Window time.Duration
+	// Buckets 窗口分成多少个桶，过期的桶整体丢弃，默认 10
+	Buckets int
+	// MinRequests 窗口内请求数达到后才判断是否打开，默认 20
+	MinRequests int
+	// ErrorRate 失败比例达到时打开，默认 0.5
+	ErrorRate float64
+	// SlowCallDuration 耗时超过时算慢调用，0 表示不统计慢调用
+	SlowCallDuration time.Duration
+	// SlowCallRate 慢调用比例达到时打开，默认 0.5
+	SlowCallRate float64
+	// OpenTimeout 打开后多久进入半开，默认 30s
+	OpenTimeout time.Duration
+	// HalfOpenRequests 半开状态放行的试探请求数，默认 1
+	HalfOpenRequests int
+	// IsFailure 判断错误是否计为失败，默认除了 context.Canceled 之外的错误都是失败
+	IsFailure func(err error) bool
+	// OnStateChange 状态变化时调用，在熔断器锁内调用，不要阻塞
+	OnStateChange func(name string, from State, to State)
+}
+
+// Breaker 熔断器，可以并发使用
+type Breaker struct {
+	options BreakerOptions
+
+	mu    sync.Mutex
+	state State
+	// 状态每次变化加一，丢弃上一个状态发出的请求结果
+	generation uint64
+	// 滚动窗口，buckets[i] 统计 [start, start+bucketSize) 内的请求
+	buckets    []bucket
+	bucketSize time.Duration
+	// 打开的时间
+	openedAt time.Time
+	// 半开状态已放行和已成功的试探请求数
+	probes    int
+	successes int
+
+	now func() time.Time
+}
+
+type bucket struct {
+	start    time.Time
+	total    int
+	failures int
+	slow     int
+}
+
+func NewBreaker(options BreakerOptions) *Breaker {
+	if options.Window <= 0 {
+		options.Window = 10 * time.Second
+	}
+	if options.Buckets <= 0 {
+		options.Buckets = 10
+	}
+	if options.MinRequests <= 0 {
+		options.MinRequests = 20
+	}
+	if options.ErrorRate <= 0 {
+		options.ErrorRate = 0.5
+	}
+	if options.SlowCallRate <= 0 {
+		options.SlowCallRate = 0.5
+	}
+	if options.OpenTimeout <= 0 {
+		options.OpenTimeout = 30 * time.Second
+	}
+	if options.HalfOpenRequests <= 0 {
+		options.HalfOpenRequests = 1
+	}
+	if options.IsFailure == nil {
+		options.IsFailure = func(err error) bool {
+			return err != nil && !errors.Is(err, context.Canceled)
+		}
+	}
+	// Window 小于 Buckets 纳秒时桶大小至少 1ns，避免 currentBucket 除以 0
+	bucketSize := options.Window / time.Duration(options.Buckets)
+	if bucketSize <= 0 {
+		bucketSize = 1
+	}
+	return &Breaker{
+		options:    options,
+		buckets:    make([]bucket, options.Buckets),
+		bucketSize: bucketSize,
+		now:        time.Now,
+	}
+}
+
+// Name 返回熔断器名字
+func (b *Breaker) Name() string {
+	return b.options.Name
+}
+
+// State 返回当前状态，打开超过 OpenTimeout 时返回半开
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh(b.now())
+	return b.state
+}
+
+// Allow 判断请求是否可以发出，可以时返回 done，请求完成后必须调用 done 报告结果
+// 熔断器打开时返回 ErrorCircuitOpen，半开并且试探请求数已满时返回 ErrorTooManyRequests
+func (b *Breaker) Allow() (done func(err error), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	b.refresh(now)
+	switch b.state {
+	case StateOpen:
+		return nil, ErrorCircuitOpen
+	case StateHalfOpen:
+		if b.probes >= b.options.HalfOpenRequests {
+			return nil, ErrorTooManyRequests
+		}
+		b.probes++
+	}
+	generation := b.generation
+	return func(err error) {
+		b.record(generation, now, err)
+	}, nil
+}
+
+// Execute 熔断器允许时执行 fn 并记录结果，fn panic 时记为失败后继续 panic
+func (b *Breaker) Execute(fn func() error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	completed := false
+	defer func() {
+		if !completed {
+			done(errorPanic)
+		}
+	}()
+	err = fn()
+	completed = true
+	done(err)
+	return err
+}
+
+func (b *Breaker) record(generation uint64, start time.Time, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	b.refresh(now)
+	if generation != b.generation {
+		return
+	}
+	failure := b.options.IsFailure(err)
+	slow := b.options.SlowCallDuration > 0 && now.Sub(start) >= b.options.SlowCallDuration
+
+	if b.state == StateHalfOpen {
+		if failure || slow {
+			b.setState(StateOpen, now)
+			return
+		}
+		b.successes++
+		if b.successes >= b.options.HalfOpenRequests {
+			b.setState(StateClosed, now)
+		}
+		return
+	}
+
+	bk := b.currentBucket(now)
+	bk.total++
+	if failure {
+		bk.failures++
+	}
+	if slow {
+		bk.slow++
+	}
+	var total, failures, slows int
+	for _, item := range b.buckets {
+		if now.Sub(item.start) < b.options.Window {
+			total += item.total
+			failures += item.failures
+			slows += item.slow
+		}
+	}
+	if total < b.options.MinRequests {
+		return
+	}
+	if float64(failures)/float64(total) >= b.options.ErrorRate ||
+		(b.options.SlowCallDuration > 0 && float64(slows)/float64(total) >= b.options.SlowCallRate) {
+		b.setState(StateOpen, now)
+	}
+}
+
+// currentBucket 返回 now 所在的桶，桶过期时清零
+func (b *Breaker) currentBucket(now time.Time) *bucket {
+	start := now.Truncate(b.bucketSize)
+	bk := &b.buckets[int(start.UnixNano()/int64(b.bucketSize))%len(b.buckets)]
+	if !bk.start.Equal(start) {
+		*bk = bucket{start: start}
+	}
+	return bk
+}
+
+// refresh 打开超过 OpenTimeout 时进入半开
+func (b *Breaker) refresh(now time.Time) {
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.options.OpenTimeout {
+		b.setState(StateHalfOpen, now)
+	}
+}
+
+func (b *Breaker) setState(state State, now time.Time) {
+	from := b.state
+	b.state = state
+	b.generation++
+	b.probes, b.successes = 0, 0
+	switch state {
+	case StateOpen:
+		b.openedAt = now
+	case StateClosed:
+		for i := range b.buckets {
+			b.buckets[i] = bucket{}
+		}
+	}
+	if b.options.OnStateChange != nil {
+		b.options.OnStateChange(b.options.Name, from, state)
+	}
+}
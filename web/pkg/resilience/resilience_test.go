@@ -0,0 +1,303 @@
+package resilience
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+	"github.com/2456868764/go-learning/web/pkg/middleware/metrics"
+)
+
+var errorDownstream = errors.New("downstream failed")
+
+// fakeClock 测试控制时间
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestBreaker(options BreakerOptions) (*Breaker, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	b := NewBreaker(options)
+	b.now = clock.Now
+	return b, clock
+}
+
+func TestBreaker_ErrorRate(t *testing.T) {
+	var changes []string
+	b, clock := newTestBreaker(BreakerOptions{
+		Name:        "user",
+		MinRequests: 4,
+		ErrorRate:   0.5,
+		OpenTimeout: time.Second,
+		OnStateChange: func(name string, from State, to State) {
+			changes = append(changes, name+" "+from.String()+"->"+to.String())
+		},
+	})
+	fail := func() error { return errorDownstream }
+	ok := func() error { return nil }
+
+	// 请求数不够不打开
+	assert.Equal(t, errorDownstream, b.Execute(fail))
+	assert.Equal(t, errorDownstream, b.Execute(fail))
+	assert.Equal(t, StateClosed, b.State())
+	assert.Nil(t, b.Execute(ok))
+	// 4 个请求 3 个失败
+	assert.Equal(t, errorDownstream, b.Execute(fail))
+	assert.Equal(t, StateOpen, b.State())
+	assert.Equal(t, ErrorCircuitOpen, b.Execute(ok))
+
+	// OpenTimeout 之后半开，只放行一个试探请求
+	clock.Add(time.Second)
+	assert.Equal(t, StateHalfOpen, b.State())
+	done, err := b.Allow()
+	assert.Nil(t, err)
+	_, err = b.Allow()
+	assert.Equal(t, ErrorTooManyRequests, err)
+	// 试探失败重新打开
+	done(errorDownstream)
+	assert.Equal(t, StateOpen, b.State())
+
+	clock.Add(time.Second)
+	assert.Nil(t, b.Execute(ok))
+	assert.Equal(t, StateClosed, b.State())
+	assert.Equal(t, []string{
+		"user closed->open", "user open->half-open", "user half-open->open",
+		"user open->half-open", "user half-open->closed",
+	}, changes)
+}
+
+func TestBreaker_RollingWindow(t *testing.T) {
+	b, clock := newTestBreaker(BreakerOptions{MinRequests: 4, Window: 10 * time.Second, Buckets: 10})
+	for i := 0; i < 3; i++ {
+		_ = b.Execute(func() error { return errorDownstream })
+	}
+	// 过了窗口之后旧的失败不再统计
+	clock.Add(11 * time.Second)
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, b.Execute(func() error { return nil }))
+	}
+	_ = b.Execute(func() error { return errorDownstream })
+	assert.Equal(t, StateClosed, b.State())
+
+	// 被取消的请求不算失败
+	for i := 0; i < 4; i++ {
+		_ = b.Execute(func() error { return context.Canceled })
+	}
+	assert.Equal(t, StateClosed, b.State())
+}
+
+func TestBreaker_SlowCalls(t *testing.T) {
+	b, clock := newTestBreaker(BreakerOptions{MinRequests: 2, SlowCallDuration: time.Second, SlowCallRate: 0.5})
+	assert.Nil(t, b.Execute(func() error { return nil }))
+	assert.Nil(t, b.Execute(func() error {
+		clock.Add(2 * time.Second)
+		return nil
+	}))
+	assert.Equal(t, StateOpen, b.State())
+
+	// 打开之前发出的请求结果不影响新状态
+	b, _ = newTestBreaker(BreakerOptions{MinRequests: 1, OpenTimeout: time.Hour})
+	done, _ := b.Allow()
+	_ = b.Execute(func() error { return errorDownstream })
+	assert.Equal(t, StateOpen, b.State())
+	done(nil)
+	assert.Equal(t, StateOpen, b.State())
+}
+
+func TestBreaker_Panic(t *testing.T) {
+	b, clock := newTestBreaker(BreakerOptions{MinRequests: 1, OpenTimeout: time.Second})
+	_ = b.Execute(func() error { return errorDownstream })
+	clock.Add(time.Second)
+	assert.Equal(t, StateHalfOpen, b.State())
+
+	// 半开时试探请求 panic 记为失败，试探名额释放，不会一直返回 ErrorTooManyRequests
+	assert.Panics(t, func() {
+		_ = b.Execute(func() error { panic("boom") })
+	})
+	assert.Equal(t, StateOpen, b.State())
+	clock.Add(time.Second)
+	assert.Nil(t, b.Execute(func() error { return nil }))
+	assert.Equal(t, StateClosed, b.State())
+
+	// 窗口小于桶数纳秒时不会除以 0
+	b, _ = newTestBreaker(BreakerOptions{Window: 5, Buckets: 10})
+	assert.Nil(t, b.Execute(func() error { return nil }))
+}
+
+func TestRetry(t *testing.T) {
+	var attempts int
+	var backoffs []time.Duration
+	options := RetryOptions{
+		Attempts:       4,
+		InitialBackoff: time.Millisecond,
+		Jitter:         -1,
+		OnRetry: func(attempt int, err error, backoff time.Duration) {
+			backoffs = append(backoffs, backoff)
+		},
+	}
+	err := Retry(context.Background(), options, func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return errorDownstream
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, []time.Duration{time.Millisecond, 2 * time.Millisecond}, backoffs)
+
+	// 次数用完返回最后一次的错误
+	attempts = 0
+	err = Retry(context.Background(), options, func(ctx context.Context) error {
+		attempts++
+		return errorDownstream
+	})
+	assert.Equal(t, errorDownstream, err)
+	assert.Equal(t, 4, attempts)
+
+	// Permanent 和熔断错误不重试
+	attempts = 0
+	err = Retry(context.Background(), options, func(ctx context.Context) error {
+		attempts++
+		return Permanent(errorDownstream)
+	})
+	assert.Equal(t, errorDownstream, err)
+	assert.Equal(t, 1, attempts)
+	err = Retry(context.Background(), options, func(ctx context.Context) error {
+		return ErrorCircuitOpen
+	})
+	assert.Equal(t, ErrorCircuitOpen, err)
+
+	// 等待时 ctx 结束立即返回
+	ctx, cancel := context.WithCancel(context.Background())
+	start := time.Now()
+	err = Retry(ctx, RetryOptions{InitialBackoff: time.Hour}, func(ctx context.Context) error {
+		cancel()
+		return errorDownstream
+	})
+	assert.Equal(t, context.Canceled, err)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestRetryOptions_Backoff(t *testing.T) {
+	options := RetryOptions{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Jitter: 0.5}
+	for attempt := 1; attempt <= 6; attempt++ {
+		backoff := options.Backoff(attempt)
+		limit := 100 * time.Millisecond << (attempt - 1)
+		if limit > time.Second {
+			limit = time.Second
+		}
+		assert.True(t, backoff <= limit && backoff >= limit/2, "attempt %d backoff %v", attempt, backoff)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Path == "/flaky" && n%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte("ok " + string(body)))
+	}))
+	defer upstream.Close()
+
+	registry := metrics.NewRegistry()
+	transport := NewTransport(TransportOptions{
+		Retry:    RetryOptions{Attempts: 3, InitialBackoff: time.Millisecond},
+		Breaker:  &BreakerOptions{MinRequests: 10, ErrorRate: 0.75, OpenTimeout: time.Hour},
+		Registry: registry,
+	})
+	client := &http.Client{Transport: transport}
+
+	// 503 重试两次后成功
+	resp, err := client.Get(upstream.URL + "/flaky")
+	assert.Nil(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "ok ", string(body))
+	assert.Equal(t, int32(3), calls.Load())
+
+	// PUT 带请求体重试时重新读取请求体
+	calls.Store(0)
+	req, _ := http.NewRequest(http.MethodPut, upstream.URL+"/flaky", strings.NewReader("data"))
+	resp, err = client.Do(req)
+	assert.Nil(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "ok data", string(body))
+
+	// POST 不重试，返回 503 响应
+	calls.Store(0)
+	resp, err = client.Post(upstream.URL+"/flaky", "text/plain", bytes.NewReader([]byte("x")))
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// 重试用完返回最后的响应，10 个请求 8 个失败，熔断器打开
+	resp, err = client.Get(upstream.URL + "/down")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Equal(t, StateOpen, transport.Breaker(strings.TrimPrefix(upstream.URL, "http://")).State())
+	_, err = client.Get(upstream.URL + "/flaky")
+	assert.True(t, errors.Is(err, ErrorCircuitOpen))
+
+	var out bytes.Buffer
+	assert.Nil(t, registry.Write(&out))
+	host := strings.TrimPrefix(upstream.URL, "http://")
+	assert.Contains(t, out.String(), `http_client_requests_total{host="`+host+`",method="GET",code="503"} 2`)
+	assert.Contains(t, out.String(), `http_client_requests_total{host="`+host+`",method="GET",code="circuit_open"} 1`)
+	assert.Contains(t, out.String(), `http_client_retries_total{host="`+host+`",method="GET"} 4`)
+	assert.Contains(t, out.String(), `http_client_circuit_state{host="`+host+`"} 1`)
+}
+
+func TestTransport_HandlerContext(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer upstream.Close()
+	client := &http.Client{Transport: NewTransport(TransportOptions{
+		Retry: RetryOptions{Attempts: 100, InitialBackoff: 50 * time.Millisecond},
+	})}
+
+	// 处理函数的 context 结束时停止重试
+	e := engine.New()
+	e.GET("/proxy", func(c *engine.Context) {
+		req, _ := http.NewRequestWithContext(c.R.Context(), http.MethodGet, upstream.URL, nil)
+		_, err := client.Do(req)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		c.StringFormat(http.StatusGatewayTimeout, "timeout")
+	}, engine.WithTimeout(100*time.Millisecond))
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proxy", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.True(t, time.Since(start) < time.Second)
+}
@@ -0,0 +1,125 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryOptions 重试配置
+type RetryOptions struct {
+	// Attempts 最多执行次数，包括第一次，默认 3
+	Attempts int
+	// InitialBackoff 第一次重试前的等待时间，默认 100ms
+	InitialBackoff time.Duration
+	// MaxBackoff 最长等待时间，默认 10s
+	MaxBackoff time.Duration
+	// Multiplier 每次重试等待时间的倍数，默认 2
+	Multiplier float64
+	// Jitter 等待时间随机减少的比例，0 到 1，避免大量客户端同时重试，默认 0.2，小于 0 表示不抖动
+	Jitter float64
+	// Retryable 判断错误是否可以重试，默认除了 Permanent、熔断和 context 错误之外都重试
+	Retryable func(err error) bool
+	// OnRetry 每次重试前调用，attempt 从 1 开始
+	OnRetry func(attempt int, err error, backoff time.Duration)
+}
+
+func (o *RetryOptions) setDefaults() {
+	if o.Attempts <= 0 {
+		o.Attempts = 3
+	}
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = 100 * time.Millisecond
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 10 * time.Second
+	}
+	if o.Multiplier < 1 {
+		o.Multiplier = 2
+	}
+	if o.Jitter == 0 {
+		o.Jitter = 0.2
+	}
+	if o.Jitter > 1 {
+		o.Jitter = 1
+	}
+	if o.Retryable == nil {
+		o.Retryable = retryable
+	}
+}
+
+// Backoff 返回第 attempt 次重试前的等待时间，attempt 从 1 开始
+func (o RetryOptions) Backoff(attempt int) time.Duration {
+	o.setDefaults()
+	backoff := float64(o.InitialBackoff) * math.Pow(o.Multiplier, float64(attempt-1))
+	if backoff > float64(o.MaxBackoff) {
+		backoff = float64(o.MaxBackoff)
+	}
+	if o.Jitter > 0 {
+		backoff -= backoff * o.Jitter * rand.Float64()
+	}
+	return time.Duration(backoff)
+}
+
+// permanentError 不重试的错误
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent 包装不需要重试的错误，比如 4xx 响应，Retry 返回原始错误
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+func retryable(err error) bool {
+	var permanent *permanentError
+	return !errors.As(err, &permanent) &&
+		!errors.Is(err, ErrorCircuitOpen) &&
+		!errors.Is(err, ErrorTooManyRequests) &&
+		!errors.Is(err, context.Canceled) &&
+		!errors.Is(err, context.DeadlineExceeded)
+}
+
+// Retry 执行 fn，失败时按指数退避加随机抖动重试，返回最后一次的错误
+// ctx 结束时停止等待并返回 ctx.Err()，fn 应该使用传入的 ctx 发起调用
+func Retry(ctx context.Context, options RetryOptions, fn func(ctx context.Context) error) error {
+	options.setDefaults()
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(ctx); err == nil {
+			return nil
+		}
+		if attempt >= options.Attempts || !options.Retryable(err) {
+			break
+		}
+		backoff := options.Backoff(attempt)
+		if options.OnRetry != nil {
+			options.OnRetry(attempt, err, backoff)
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return permanent.err
+	}
+	return err
+}
@@ -0,0 +1,226 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/2456868764/go-learning/web/pkg/middleware/metrics"
+)
+
+// StatusError 上游返回了可以重试的状态码，最后一次仍然失败时 RoundTrip 返回该响应而不是错误
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("resilience: upstream responded %d", e.StatusCode)
+}
+
+// TransportOptions 出站请求配置
+type TransportOptions struct {
+	// Base 实际发送请求的 RoundTripper，默认 http.DefaultTransport
+	Base http.RoundTripper
+	// Retry 重试配置，Attempts 为 1 表示不重试
+	Retry RetryOptions
+	// Breaker 每个 host 一个熔断器，Name 使用 host，nil 表示不熔断
+	Breaker *BreakerOptions
+	// RetryStatus 计为失败并重试的状态码，默认 502、503、504
+	RetryStatus []int
+	// Registry 记录指标的注册表，比如 metrics.Metrics.Registry()，nil 表示不记录
+	Registry *metrics.Registry
+	// Namespace 指标名前缀，默认 http_client
+	Namespace string
+}
+
+// Transport 带熔断、重试和指标的 http.RoundTripper，在处理函数里调用下游服务时使用
+//
+//	client := &http.Client{Transport: resilience.NewTransport(resilience.TransportOptions{...})}
+//	req, _ := http.NewRequestWithContext(c.R.Context(), http.MethodGet, url, nil)
+//	resp, err := client.Do(req)
+//
+// 请求使用处理函数的 context，客户端断开时停止重试
+// 只重试幂等方法和带 Idempotency-Key 的请求，有请求体时需要 GetBody，http.NewRequest 会自动设置
+type Transport struct {
+	options     TransportOptions
+	retryStatus map[int]bool
+
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+
+	requests *metrics.CounterVec
+	duration *metrics.HistogramVec
+	retries  *metrics.CounterVec
+	state    *metrics.GaugeVec
+}
+
+func NewTransport(options TransportOptions) *Transport {
+	if options.Base == nil {
+		options.Base = http.DefaultTransport
+	}
+	if len(options.RetryStatus) == 0 {
+		options.RetryStatus = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	}
+	if options.Namespace == "" {
+		options.Namespace = "http_client"
+	}
+	t := &Transport{
+		options:     options,
+		retryStatus: make(map[int]bool, len(options.RetryStatus)),
+		breakers:    make(map[string]*Breaker),
+	}
+	for _, status := range options.RetryStatus {
+		t.retryStatus[status] = true
+	}
+	if r := options.Registry; r != nil {
+		t.requests = r.NewCounterVec(options.Namespace+"_requests_total",
+			"Total number of outbound HTTP requests, code is the status or error, circuit_open.", "host", "method", "code")
+		t.duration = r.NewHistogramVec(options.Namespace+"_request_duration_seconds",
+			"Outbound HTTP request latency in seconds.", nil, "host", "method")
+		t.retries = r.NewCounterVec(options.Namespace+"_retries_total",
+			"Total number of outbound HTTP request retries.", "host", "method")
+		t.state = r.NewGaugeVec(options.Namespace+"_circuit_state",
+			"Circuit breaker state, 0 closed, 1 open, 2 half-open.", "host")
+	}
+	return t
+}
+
+// Breaker 返回 host 对应的熔断器，没有配置熔断时返回 nil
+func (t *Transport) Breaker(host string) *Breaker {
+	if t.options.Breaker == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if b, ok := t.breakers[host]; ok {
+		return b
+	}
+	options := *t.options.Breaker
+	options.Name = host
+	onStateChange := options.OnStateChange
+	options.OnStateChange = func(name string, from State, to State) {
+		if t.state != nil {
+			t.state.Set(float64(to), name)
+		}
+		if onStateChange != nil {
+			onStateChange(name, from, to)
+		}
+	}
+	b := NewBreaker(options)
+	t.breakers[host] = b
+	if t.state != nil {
+		t.state.Set(float64(StateClosed), host)
+	}
+	return b
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	breaker := t.Breaker(host)
+	retry := t.options.Retry
+	if !canRetry(req) {
+		retry.Attempts = 1
+	}
+	onRetry := retry.OnRetry
+	retry.OnRetry = func(attempt int, err error, backoff time.Duration) {
+		if t.retries != nil {
+			t.retries.Inc(host, req.Method)
+		}
+		if onRetry != nil {
+			onRetry(attempt, err, backoff)
+		}
+	}
+
+	var resp *http.Response
+	first := true
+	err := Retry(req.Context(), retry, func(ctx context.Context) error {
+		// 上一次可以重试的响应不再返回，关闭后重试
+		if resp != nil {
+			drain(resp)
+			resp = nil
+		}
+		out := req
+		if !first {
+			out = req.Clone(ctx)
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return Permanent(err)
+				}
+				out.Body = body
+			}
+		}
+		first = false
+
+		var done func(err error)
+		if breaker != nil {
+			var err error
+			if done, err = breaker.Allow(); err != nil {
+				t.observe(host, req.Method, "circuit_open", 0)
+				return err
+			}
+		}
+		start := time.Now()
+		r, err := t.options.Base.RoundTrip(out)
+		if err == nil && t.retryStatus[r.StatusCode] {
+			resp = r
+			err = &StatusError{StatusCode: r.StatusCode}
+		}
+		if done != nil {
+			done(err)
+		}
+		if err != nil {
+			code := "error"
+			var statusError *StatusError
+			if errors.As(err, &statusError) {
+				code = strconv.Itoa(statusError.StatusCode)
+			}
+			t.observe(host, req.Method, code, time.Since(start))
+			return err
+		}
+		t.observe(host, req.Method, strconv.Itoa(r.StatusCode), time.Since(start))
+		resp = r
+		return nil
+	})
+	var statusError *StatusError
+	if err != nil && !(errors.As(err, &statusError) && resp != nil) {
+		if resp != nil {
+			drain(resp)
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (t *Transport) observe(host string, method string, code string, duration time.Duration) {
+	if t.requests == nil {
+		return
+	}
+	t.requests.Inc(host, method, code)
+	if duration > 0 {
+		t.duration.Observe(duration.Seconds(), host, method)
+	}
+}
+
+// canRetry 幂等方法和带 Idempotency-Key 的请求，并且请求体可以重新读取
+func canRetry(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+	default:
+		if req.Header.Get("Idempotency-Key") == "" {
+			return false
+		}
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// drain 读完并关闭响应体，连接可以复用
+func drain(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	_ = resp.Body.Close()
+}
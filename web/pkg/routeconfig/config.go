@@ -0,0 +1,216 @@
+package routeconfig
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config 路由配置文件，JSON 是 YAML 的子集，两种格式使用同一个解析器
+//
+//	middlewares: [requestid]
+//	routes:
+//	  - method: GET
+//	    pattern: /user/:id<int>
+//	    handler: getUser
+//	    name: user
+//	    middlewares: [auth]
+//	    timeout: 3s
+//	    max_body_bytes: 1048576
+//	  - method: POST
+//	    pattern: /upload
+//	    handler: upload
+//	    disabled: true
+type Config struct {
+	// Middlewares 配置文件中所有路由共用的中间件，在路由自己的中间件之前执行
+	Middlewares []string
+	Routes      []RouteConfig
+	// middlewares 在配置文件里的行号
+	middlewaresLine int
+}
+
+// RouteConfig 一条路由配置
+type RouteConfig struct {
+	// Method 请求方法，ANY 表示所有支持的方法
+	Method  string
+	Pattern string
+	// Handler 处理函数在 Registry 里的名字
+	Handler string
+	// Name 路由名字，用于 Engine.URL
+	Name string
+	// Host 路由所属 Host 模式，空表示默认路由树
+	Host string
+	// Middlewares 路由中间件在 Registry 里的名字，按顺序执行
+	Middlewares []string
+	// Timeout 处理超时时间，0 表示不限制
+	Timeout time.Duration
+	// MaxBodyBytes 请求体大小限制，0 表示沿用 Engine.MaxBodyBytes，小于 0 表示不限制
+	MaxBodyBytes int64
+	// Disabled 为 true 时不注册，用于临时下线接口
+	Disabled bool
+	// Line 路由在配置文件里的行号
+	Line int
+}
+
+// Error 配置错误，带有配置文件位置
+type Error struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// Errors 一次解析或者校验发现的所有错误
+type Errors []*Error
+
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// parser 解析时收集错误，遇到错误继续解析后面的内容
+type parser struct {
+	file   string
+	errors Errors
+}
+
+func (p *parser) errorf(line int, format string, args ...any) {
+	p.errors = append(p.errors, &Error{File: p.file, Line: line, Msg: fmt.Sprintf(format, args...)})
+}
+
+// Parse 解析 YAML 或者 JSON 格式的路由配置，file 只用于错误信息
+// 返回的错误是 Errors，包含每个错误所在的行
+func Parse(file string, data []byte) (*Config, error) {
+	p := &parser{file: file}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, Errors{{File: file, Line: yamlErrorLine(err), Msg: err.Error()}}
+	}
+	config := &Config{}
+	// 空文件表示没有路由
+	if len(doc.Content) == 0 {
+		return config, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		p.errorf(root.Line, "config must be a mapping with routes")
+		return nil, p.errors
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch key.Value {
+		case "middlewares":
+			config.Middlewares = p.strings(value, key.Value)
+			config.middlewaresLine = value.Line
+		case "routes":
+			if value.Kind != yaml.SequenceNode {
+				p.errorf(value.Line, "routes must be a list")
+				continue
+			}
+			for _, item := range value.Content {
+				if route, ok := p.route(item); ok {
+					config.Routes = append(config.Routes, route)
+				}
+			}
+		default:
+			p.errorf(key.Line, "unknown field %q", key.Value)
+		}
+	}
+	if len(p.errors) > 0 {
+		return nil, p.errors
+	}
+	return config, nil
+}
+
+func (p *parser) route(n *yaml.Node) (RouteConfig, bool) {
+	route := RouteConfig{Line: n.Line}
+	if n.Kind != yaml.MappingNode {
+		p.errorf(n.Line, "route must be a mapping")
+		return route, false
+	}
+	count := len(p.errors)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		switch key.Value {
+		case "method":
+			route.Method = strings.ToUpper(p.string(value, key.Value))
+		case "pattern":
+			route.Pattern = p.string(value, key.Value)
+		case "handler":
+			route.Handler = p.string(value, key.Value)
+		case "name":
+			route.Name = p.string(value, key.Value)
+		case "host":
+			route.Host = p.string(value, key.Value)
+		case "middlewares":
+			route.Middlewares = p.strings(value, key.Value)
+		case "timeout":
+			timeout, err := time.ParseDuration(value.Value)
+			if value.Kind != yaml.ScalarNode || err != nil || timeout < 0 {
+				p.errorf(value.Line, "invalid timeout %q, want a duration like 3s", value.Value)
+				continue
+			}
+			route.Timeout = timeout
+		case "max_body_bytes":
+			if err := value.Decode(&route.MaxBodyBytes); err != nil {
+				p.errorf(value.Line, "max_body_bytes must be an integer")
+			}
+		case "disabled":
+			if err := value.Decode(&route.Disabled); err != nil {
+				p.errorf(value.Line, "disabled must be a boolean")
+			}
+		default:
+			p.errorf(key.Line, "unknown route field %q", key.Value)
+		}
+	}
+	if route.Method == "" {
+		p.errorf(n.Line, "route method is required")
+	}
+	if route.Pattern == "" {
+		p.errorf(n.Line, "route pattern is required")
+	}
+	if route.Handler == "" {
+		p.errorf(n.Line, "route handler is required")
+	}
+	return route, len(p.errors) == count
+}
+
+func (p *parser) string(n *yaml.Node, field string) string {
+	if n.Kind != yaml.ScalarNode {
+		p.errorf(n.Line, "%s must be a string", field)
+		return ""
+	}
+	return n.Value
+}
+
+func (p *parser) strings(n *yaml.Node, field string) []string {
+	if n.Kind != yaml.SequenceNode {
+		p.errorf(n.Line, "%s must be a list", field)
+		return nil
+	}
+	values := make([]string, 0, len(n.Content))
+	for _, item := range n.Content {
+		values = append(values, p.string(item, field))
+	}
+	return values
+}
+
+// yamlErrorLine 从 yaml 语法错误 "yaml: line 3: ..." 中取出行号
+func yamlErrorLine(err error) int {
+	var line int
+	if _, scanErr := fmt.Sscanf(err.Error(), "yaml: line %d:", &line); scanErr != nil {
+		return 0
+	}
+	return line
+}
@@ -0,0 +1,254 @@
+package routeconfig
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+)
+
+// anyMethod 配置文件里表示所有方法，和 Engine.Any 一样展开成下面的方法
+const anyMethod = "ANY"
+
+var anyMethods = []string{
+	http.MethodGet,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodDelete,
+	http.MethodPatch,
+}
+
+// routeKey 唯一确定一条注册的路由
+type routeKey struct {
+	host    string
+	method  string
+	pattern string
+}
+
+// Loader 从配置文件加载路由注册到 Engine，重新加载时只替换配置文件管理的路由，代码注册的路由不受影响
+// 配置文件和代码注册相同路由时，配置文件的覆盖代码注册的
+type Loader struct {
+	engine   *engine.Engine
+	registry *Registry
+	path     string
+	// OnReload Watch 重新加载后调用，err 为 nil 表示成功，默认打印日志
+	OnReload func(err error)
+
+	mu sync.Mutex
+	// 上次加载注册的路由和路由名字
+	applied map[routeKey]string
+	modTime time.Time
+	size    int64
+}
+
+func NewLoader(e *engine.Engine, registry *Registry, path string) *Loader {
+	return &Loader{
+		engine:   e,
+		registry: registry,
+		path:     path,
+		applied:  make(map[routeKey]string),
+	}
+}
+
+// Load 读取配置文件，校验通过后注册路由，删除上次加载有而这次没有或者禁用的路由
+// 配置有错误或者注册失败时返回 Errors 并且不修改路由，整个配置的路由一次原子替换
+func (l *Loader) Load() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return err
+	}
+	l.modTime, l.size = info.ModTime(), info.Size()
+	config, err := Parse(l.path, data)
+	if err != nil {
+		return err
+	}
+	if errs := l.validate(config); len(errs) > 0 {
+		return errs
+	}
+	return l.apply(config)
+}
+
+// validate 检查名字是否登记，再把路由注册到一个新的 Engine 上，提前发现 pattern、方法和名字错误
+func (l *Loader) validate(config *Config) Errors {
+	var errs Errors
+	for _, name := range config.Middlewares {
+		if _, ok := l.registry.middleware(name); !ok {
+			errs = append(errs, l.errorf(config.middlewaresLine, "unknown middleware %q", name))
+		}
+	}
+	dryRun := engine.New()
+	seen := make(map[routeKey]int)
+	for _, route := range config.Routes {
+		if route.Disabled {
+			continue
+		}
+		if _, ok := l.registry.handler(route.Handler); !ok {
+			errs = append(errs, l.errorf(route.Line, "unknown handler %q", route.Handler))
+		}
+		for _, name := range route.Middlewares {
+			if _, ok := l.registry.middleware(name); !ok {
+				errs = append(errs, l.errorf(route.Line, "unknown middleware %q", name))
+			}
+		}
+		for _, method := range methods(route) {
+			key := routeKey{host: route.Host, method: method, pattern: route.Pattern}
+			if line, ok := seen[key]; ok {
+				errs = append(errs, l.errorf(route.Line, "duplicate route %s %s, first defined at line %d", method, route.Pattern, line))
+				continue
+			}
+			seen[key] = route.Line
+			if err := targetFor(dryRun, route).AddRoute(method, route.Pattern, func(c *engine.Context) {}, options(route, nil)...); err != nil {
+				errs = append(errs, l.errorf(route.Line, "%s %s: %v", method, route.Pattern, err))
+			}
+		}
+	}
+	return errs
+}
+
+// apply 在同一份路由快照上删除不再需要和名字改变的路由，再注册新路由
+// 有路由注册失败时放弃全部修改，当前路由保持不变
+func (l *Loader) apply(config *Config) error {
+	wanted := make(map[routeKey]RouteConfig)
+	// Engine.Host 不能在 UpdateRoutes 里调用，提前取好
+	hosts := make(map[string]*engine.Host)
+	for _, route := range config.Routes {
+		if route.Disabled {
+			continue
+		}
+		for _, method := range methods(route) {
+			wanted[routeKey{host: route.Host, method: method, pattern: route.Pattern}] = route
+		}
+		if route.Host != "" {
+			hosts[route.Host] = l.engine.Host(route.Host)
+		}
+	}
+	for key := range l.applied {
+		if key.host != "" {
+			hosts[key.host] = l.engine.Host(key.host)
+		}
+	}
+
+	var errs Errors
+	applied := make(map[routeKey]string, len(wanted))
+	middlewares := l.middlewares(config.Middlewares)
+	err := l.engine.UpdateRoutes(func(b *engine.RouteBatch) error {
+		for key, name := range l.applied {
+			if route, ok := wanted[key]; ok && route.Name == name {
+				continue
+			}
+			err := b.RemoveRoute(hosts[key.host], key.method, key.pattern)
+			if err != nil && err != engine.ErrorRouteNotFound {
+				errs = append(errs, &Error{File: l.path, Msg: err.Error()})
+			}
+		}
+		for _, route := range config.Routes {
+			if route.Disabled {
+				continue
+			}
+			handler, _ := l.registry.handler(route.Handler)
+			opts := options(route, append(middlewares[:len(middlewares):len(middlewares)], l.middlewares(route.Middlewares)...))
+			for _, method := range methods(route) {
+				if err := b.AddRoute(hosts[route.Host], method, route.Pattern, handler, opts...); err != nil {
+					// 比如和代码注册的路由名字冲突，继续注册其它路由，把错误一起返回
+					errs = append(errs, l.errorf(route.Line, "%s %s: %v", method, route.Pattern, err))
+					continue
+				}
+				applied[routeKey{host: route.Host, method: method, pattern: route.Pattern}] = route.Name
+			}
+		}
+		if len(errs) > 0 {
+			return errs
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	l.applied = applied
+	return nil
+}
+
+// Watch 每隔 interval 检查配置文件修改时间和大小，变化时重新加载，ctx 结束时返回
+// 重新加载失败时保留当前路由，结果通过 OnReload 通知
+func (l *Loader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !l.changed() {
+			continue
+		}
+		err := l.Load()
+		if l.OnReload != nil {
+			l.OnReload(err)
+		} else if err != nil {
+			log.Printf("routeconfig: reload %s failed:\n%v", l.path, err)
+		} else {
+			log.Printf("routeconfig: reloaded %s", l.path)
+		}
+	}
+}
+
+func (l *Loader) changed() bool {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return !info.ModTime().Equal(l.modTime) || info.Size() != l.size
+}
+
+func (l *Loader) middlewares(names []string) []engine.HandlerFunc {
+	middlewares := make([]engine.HandlerFunc, 0, len(names))
+	for _, name := range names {
+		middleware, _ := l.registry.middleware(name)
+		middlewares = append(middlewares, middleware)
+	}
+	return middlewares
+}
+
+func (l *Loader) errorf(line int, format string, args ...any) *Error {
+	return &Error{File: l.path, Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func methods(route RouteConfig) []string {
+	if route.Method == anyMethod {
+		return anyMethods
+	}
+	return []string{route.Method}
+}
+
+func targetFor(e *engine.Engine, route RouteConfig) engine.Routable {
+	if route.Host == "" {
+		return e
+	}
+	return e.Host(route.Host)
+}
+
+func options(route RouteConfig, middlewares []engine.HandlerFunc) []engine.RouteOption {
+	opts := []engine.RouteOption{
+		engine.WithMiddlewares(middlewares...),
+		engine.WithTimeout(route.Timeout),
+		engine.WithMaxBodyBytes(route.MaxBodyBytes),
+	}
+	if route.Name != "" {
+		opts = append(opts, engine.WithName(route.Name))
+	}
+	return opts
+}
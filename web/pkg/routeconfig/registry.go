@@ -0,0 +1,51 @@
+package routeconfig
+
+import (
+	"sync"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+)
+
+// Registry 按名字登记处理函数和中间件，配置文件通过名字引用
+type Registry struct {
+	mu          sync.RWMutex
+	handlers    map[string]engine.HandlerFunc
+	middlewares map[string]engine.HandlerFunc
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		handlers:    make(map[string]engine.HandlerFunc),
+		middlewares: make(map[string]engine.HandlerFunc),
+	}
+}
+
+// Handler 登记处理函数，同名覆盖
+func (r *Registry) Handler(name string, handler engine.HandlerFunc) *Registry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[name] = handler
+	return r
+}
+
+// Middleware 登记中间件，同名覆盖
+func (r *Registry) Middleware(name string, middleware engine.HandlerFunc) *Registry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middlewares[name] = middleware
+	return r
+}
+
+func (r *Registry) handler(name string) (engine.HandlerFunc, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	handler, ok := r.handlers[name]
+	return handler, ok
+}
+
+func (r *Registry) middleware(name string) (engine.HandlerFunc, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	middleware, ok := r.middlewares[name]
+	return middleware, ok
+}
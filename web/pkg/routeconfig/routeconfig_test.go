@@ -0,0 +1,335 @@
+package routeconfig
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/2456868764/go-learning/web/pkg/engine"
+)
+
+func TestParse(t *testing.T) {
+	config, err := Parse("routes.yaml", []byte(`
+middlewares: [log]
+routes:
+  - method: get
+    pattern: /user/:id<int>
+    handler: user
+    name: user
+    middlewares: [auth]
+    timeout: 3s
+    max_body_bytes: 1024
+  - method: POST
+    pattern: /upload
+    handler: upload
+    disabled: true
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"log"}, config.Middlewares)
+	assert.Equal(t, []RouteConfig{
+		{Method: "GET", Pattern: "/user/:id<int>", Handler: "user", Name: "user", Middlewares: []string{"auth"},
+			Timeout: 3 * time.Second, MaxBodyBytes: 1024, Line: 4},
+		{Method: "POST", Pattern: "/upload", Handler: "upload", Disabled: true, Line: 11},
+	}, config.Routes)
+
+	config, err = Parse("routes.json", []byte(`{
+  "routes": [
+    {"method": "GET", "pattern": "/", "handler": "index"}
+  ]
+}`))
+	assert.Nil(t, err)
+	assert.Equal(t, []RouteConfig{{Method: "GET", Pattern: "/", Handler: "index", Line: 3}}, config.Routes)
+
+	config, err = Parse("empty.yaml", nil)
+	assert.Nil(t, err)
+	assert.Empty(t, config.Routes)
+}
+
+func TestParse_Errors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{
+			name:    "syntax",
+			data:    "routes:\n  - method: GET\n    pattern: a: b",
+			wantErr: "routes.yaml:3: yaml: line 3: mapping values are not allowed in this context",
+		},
+		{
+			name:    "unknown field",
+			data:    "routes:\n  - method: GET\n    pattern: /\n    handler: index\n    timout: 3s",
+			wantErr: `routes.yaml:5: unknown route field "timout"`,
+		},
+		{
+			name: "invalid values",
+			data: "routes:\n  - method: GET\n    pattern: /\n    handler: index\n    timeout: soon\n    max_body_bytes: 1MB",
+			wantErr: "routes.yaml:5: invalid timeout \"soon\", want a duration like 3s\n" +
+				"routes.yaml:6: max_body_bytes must be an integer",
+		},
+		{
+			name: "required",
+			data: "routes:\n  - method: GET\n  - pattern: /",
+			wantErr: "routes.yaml:2: route pattern is required\nroutes.yaml:2: route handler is required\n" +
+				"routes.yaml:3: route method is required\nroutes.yaml:3: route handler is required",
+		},
+		{
+			name:    "not a list",
+			data:    "routes: /",
+			wantErr: "routes.yaml:1: routes must be a list",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse("routes.yaml", []byte(tc.data))
+			assert.Equal(t, tc.wantErr, err.Error())
+			_, ok := err.(Errors)
+			assert.True(t, ok)
+		})
+	}
+}
+
+func newTestRegistry() *Registry {
+	return NewRegistry().
+		Handler("user", func(c *engine.Context) {
+			c.StringOk("user " + c.PathParams["id"])
+		}).
+		Handler("slow", func(c *engine.Context) {
+			<-c.R.Context().Done()
+		}).
+		Handler("upload", func(c *engine.Context) {
+			var body map[string]any
+			if err := c.ReadJsonObject(&body); err != nil {
+				c.StringFormat(http.StatusRequestEntityTooLarge, err.Error())
+				return
+			}
+			c.StringOk("ok")
+		}).
+		Middleware("log", func(c *engine.Context) {
+			c.SetHeader("X-Log", "1")
+			c.Next()
+		}).
+		Middleware("auth", func(c *engine.Context) {
+			if c.GetHeader("Authorization") == "" {
+				c.StringFormat(http.StatusUnauthorized, "unauthorized")
+				c.Abort()
+				return
+			}
+			c.Next()
+		})
+}
+
+func writeConfig(t *testing.T, path string, data string) {
+	assert.Nil(t, os.WriteFile(path, []byte(strings.TrimSpace(data)), 0o644))
+}
+
+func serve(e *engine.Engine, method string, path string, body string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestLoader_Load(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	writeConfig(t, path, `
+middlewares: [log]
+routes:
+  - method: GET
+    pattern: /user/:id<int>
+    handler: user
+    name: user
+    middlewares: [auth]
+  - method: GET
+    pattern: /slow
+    handler: slow
+    timeout: 10ms
+  - method: POST
+    pattern: /upload
+    handler: upload
+    max_body_bytes: 8
+  - method: ANY
+    pattern: /any
+    handler: user
+    host: api.example.com
+`)
+	e := engine.New()
+	e.GET("/health", func(c *engine.Context) {
+		c.StringOk("ok")
+	})
+	loader := NewLoader(e, newTestRegistry(), path)
+	assert.Nil(t, loader.Load())
+
+	w := serve(e, http.MethodGet, "/user/1", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-Log"))
+	w = serve(e, http.MethodGet, "/user/1", "", map[string]string{"Authorization": "token"})
+	assert.Equal(t, "user 1", w.Body.String())
+	url, err := e.URL("user", "id", "2")
+	assert.Nil(t, err)
+	assert.Equal(t, "/user/2", url)
+
+	assert.Equal(t, http.StatusServiceUnavailable, serve(e, http.MethodGet, "/slow", "", nil).Code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, serve(e, http.MethodPost, "/upload", `{"name":"large"}`, nil).Code)
+	assert.Equal(t, http.StatusOK, serve(e, http.MethodPost, "/upload", `{}`, nil).Code)
+
+	req := httptest.NewRequest(http.MethodPut, "/any", nil)
+	req.Host = "api.example.com"
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, e.Routes(), 9)
+
+	// 重新加载：禁用 /upload，删除 /slow 和 /any，/user 改名，代码注册的路由不受影响
+	writeConfig(t, path, `
+routes:
+  - method: GET
+    pattern: /user/:id<int>
+    handler: user
+    name: profile
+  - method: POST
+    pattern: /upload
+    handler: upload
+    disabled: true
+`)
+	assert.Nil(t, loader.Load())
+	assert.Equal(t, "user 1", serve(e, http.MethodGet, "/user/1", "", nil).Body.String())
+	assert.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/slow", "", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve(e, http.MethodPost, "/upload", "{}", nil).Code)
+	assert.Equal(t, http.StatusOK, serve(e, http.MethodGet, "/health", "", nil).Code)
+	_, err = e.URL("user")
+	assert.Equal(t, engine.ErrorRouteNameNotFound, err)
+	url, err = e.URL("profile", "id", "3")
+	assert.Nil(t, err)
+	assert.Equal(t, "/user/3", url)
+	assert.Len(t, e.Routes(), 2)
+}
+
+func TestLoader_ValidationErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	writeConfig(t, path, `
+routes:
+  - method: GET
+    pattern: /user
+    handler: user
+`)
+	e := engine.New()
+	loader := NewLoader(e, newTestRegistry(), path)
+	assert.Nil(t, loader.Load())
+
+	writeConfig(t, path, `
+middlewares: [cors]
+routes:
+  - method: GET
+    pattern: /user
+    handler: users
+  - method: GET
+    pattern: /order
+    handler: user
+    middlewares: [auth, limit]
+  - method: GET
+    pattern: /order
+    handler: user
+  - method: TRACE
+    pattern: /trace
+    handler: user
+  - method: GET
+    pattern: /bad/:id<float>
+    handler: user
+`)
+	err := loader.Load()
+	assert.Equal(t, path+`:1: unknown middleware "cors"
+`+path+`:3: unknown handler "users"
+`+path+`:6: unknown middleware "limit"
+`+path+`:10: duplicate route GET /order, first defined at line 6
+`+path+`:13: TRACE /trace: invalid http method
+`+path+`:16: GET /bad/:id<float>: unknown path param type`, err.Error())
+	// 校验失败时保留原来的路由
+	assert.Equal(t, http.StatusOK, serve(e, http.MethodGet, "/user", "", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/order", "", nil).Code)
+}
+
+func TestLoader_ApplyFailureKeepsRoutes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	writeConfig(t, path, `
+routes:
+  - method: GET
+    pattern: /user/:id<int>
+    handler: user
+    name: user
+`)
+	e := engine.New()
+	e.GET("/admin", func(c *engine.Context) {
+		c.StringOk("admin")
+	}, engine.WithName("admin"))
+	loader := NewLoader(e, newTestRegistry(), path)
+	assert.Nil(t, loader.Load())
+
+	// 改名和代码注册的路由冲突，已经删除的旧路由不能丢，新路由也不生效
+	writeConfig(t, path, `
+routes:
+  - method: GET
+    pattern: /user/:id<int>
+    handler: user
+    name: admin
+  - method: GET
+    pattern: /order
+    handler: user
+`)
+	err := loader.Load()
+	assert.Equal(t, path+":2: GET /user/:id<int>: duplicate route name", err.Error())
+	assert.Equal(t, "user 1", serve(e, http.MethodGet, "/user/1", "", nil).Body.String())
+	assert.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/order", "", nil).Code)
+	url, err := e.URL("user", "id", "1")
+	assert.Nil(t, err)
+	assert.Equal(t, "/user/1", url)
+	assert.Len(t, e.Routes(), 2)
+
+	// 修正配置后重新加载，失败那次没有留下半截状态
+	writeConfig(t, path, `
+routes:
+  - method: GET
+    pattern: /order
+    handler: user
+`)
+	assert.Nil(t, loader.Load())
+	assert.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/user/1", "", nil).Code)
+	assert.Equal(t, http.StatusOK, serve(e, http.MethodGet, "/order", "", nil).Code)
+	assert.Equal(t, http.StatusOK, serve(e, http.MethodGet, "/admin", "", nil).Code)
+	assert.Len(t, e.Routes(), 2)
+}
+
+func TestLoader_Watch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.json")
+	writeConfig(t, path, `{"routes": [{"method": "GET", "pattern": "/user/:id", "handler": "user"}]}`)
+	e := engine.New()
+	loader := NewLoader(e, newTestRegistry(), path)
+	assert.Nil(t, loader.Load())
+
+	reloaded := make(chan error, 1)
+	loader.OnReload = func(err error) {
+		reloaded <- err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go loader.Watch(ctx, 5*time.Millisecond)
+
+	writeConfig(t, path, `{"routes": [{"method": "GET", "pattern": "/user/:id", "handler": "user", "disabled": true}]}`)
+	select {
+	case err := <-reloaded:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("config not reloaded")
+	}
+	assert.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/user/1", "", nil).Code)
+}
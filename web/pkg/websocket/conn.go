@@ -0,0 +1,419 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 消息类型，和 RFC 6455 opcode 一致
+const (
+	continuationFrame = 0
+	TextMessage       = 1
+	BinaryMessage     = 2
+	CloseMessage      = 8
+	PingMessage       = 9
+	PongMessage       = 10
+)
+
+// 关闭状态码
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseInvalidFramePayloadData = 1007
+	CloseMessageTooBig           = 1009
+)
+
+const (
+	finalBit = 0x80
+	rsvBits  = 0x70
+	maskBit  = 0x80
+	// 控制帧负载最大长度
+	maxControlPayload = 125
+	// 默认单条消息最大长度
+	defaultMaxMessageSize = 1 << 20
+	// 发送关闭帧的写超时
+	closeWriteTimeout = time.Second
+)
+
+var ErrorProtocol = errors.New("websocket: protocol error")
+var ErrorMessageTooLarge = errors.New("websocket: message too large")
+var ErrorCloseSent = errors.New("websocket: close frame already sent")
+var ErrorInvalidMessageType = errors.New("websocket: invalid message type")
+
+// CloseError 收到对方关闭帧
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+// Conn WebSocket 连接，ReadMessage 只能在一个协程里调用，写方法可以并发调用
+type Conn struct {
+	conn     net.Conn
+	br       *bufio.Reader
+	isServer bool
+	// 协商的子协议
+	subprotocol string
+
+	// 单条消息最大长度，超过返回 ErrorMessageTooLarge 并以 1009 关闭连接
+	maxMessageSize int64
+	// 发送消息分片大小，0 表示不分片
+	fragmentSize int
+
+	writeMu   sync.Mutex
+	bw        *bufio.Writer
+	closeSent bool
+
+	pingHandler func(data []byte) error
+	pongHandler func(data []byte) error
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, bw *bufio.Writer, isServer bool, options Options) *Conn {
+	if br == nil {
+		br = bufio.NewReader(conn)
+	}
+	if bw == nil {
+		bw = bufio.NewWriter(conn)
+	}
+	c := &Conn{
+		conn:           conn,
+		br:             br,
+		bw:             bw,
+		isServer:       isServer,
+		maxMessageSize: options.MaxMessageSize,
+		fragmentSize:   options.FragmentSize,
+	}
+	if c.maxMessageSize <= 0 {
+		c.maxMessageSize = defaultMaxMessageSize
+	}
+	c.pingHandler = func(data []byte) error {
+		return c.WriteControl(PongMessage, data)
+	}
+	return c
+}
+
+// Subprotocol 返回握手协商的子协议
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// RemoteAddr 对方地址
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// SetReadDeadline 设置读超时
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline 设置写超时
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// SetPingHandler 设置收到 ping 时的处理函数，默认回复 pong
+func (c *Conn) SetPingHandler(h func(data []byte) error) {
+	c.pingHandler = h
+}
+
+// SetPongHandler 设置收到 pong 时的处理函数，默认忽略
+func (c *Conn) SetPongHandler(h func(data []byte) error) {
+	c.pongHandler = h
+}
+
+// frame 一个数据帧
+type frame struct {
+	fin     bool
+	opcode  int
+	payload []byte
+}
+
+// ReadMessage 读取一条完整消息，分片消息会合并，控制帧在内部处理
+// 收到关闭帧时回复关闭帧并返回 *CloseError
+func (c *Conn) ReadMessage() (messageType int, data []byte, err error) {
+	messageType = -1
+	for {
+		f, err := c.readFrame(c.maxMessageSize - int64(len(data)))
+		if err != nil {
+			return -1, nil, c.handleReadError(err)
+		}
+
+		switch f.opcode {
+		case PingMessage:
+			if c.pingHandler != nil {
+				if err = c.pingHandler(f.payload); err != nil {
+					return -1, nil, err
+				}
+			}
+		case PongMessage:
+			if c.pongHandler != nil {
+				if err = c.pongHandler(f.payload); err != nil {
+					return -1, nil, err
+				}
+			}
+		case CloseMessage:
+			return -1, nil, c.handleClose(f.payload)
+		case TextMessage, BinaryMessage:
+			// 上一条分片消息还没有结束又来了新消息
+			if messageType != -1 {
+				return -1, nil, c.handleReadError(ErrorProtocol)
+			}
+			messageType = f.opcode
+			data = f.payload
+			if f.fin {
+				return messageType, data, c.validate(messageType, data)
+			}
+		case continuationFrame:
+			if messageType == -1 {
+				return -1, nil, c.handleReadError(ErrorProtocol)
+			}
+			data = append(data, f.payload...)
+			if f.fin {
+				return messageType, data, c.validate(messageType, data)
+			}
+		}
+	}
+}
+
+// validate 文本消息必须是合法 UTF-8
+func (c *Conn) validate(messageType int, data []byte) error {
+	if messageType == TextMessage && !utf8.Valid(data) {
+		_ = c.WriteClose(CloseInvalidFramePayloadData, "invalid utf8")
+		return ErrorProtocol
+	}
+	return nil
+}
+
+// handleReadError 协议错误和消息过大时发送对应的关闭帧
+func (c *Conn) handleReadError(err error) error {
+	switch err {
+	case ErrorProtocol:
+		_ = c.WriteClose(CloseProtocolError, "")
+	case ErrorMessageTooLarge:
+		_ = c.WriteClose(CloseMessageTooBig, "")
+	}
+	return err
+}
+
+// handleClose 解析关闭帧并回复，完成关闭握手
+func (c *Conn) handleClose(payload []byte) error {
+	closeErr := &CloseError{Code: CloseNoStatusReceived}
+	switch {
+	case len(payload) == 1:
+		return c.handleReadError(ErrorProtocol)
+	case len(payload) >= 2:
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Text = string(payload[2:])
+		if !utf8.Valid(payload[2:]) {
+			return c.handleReadError(ErrorProtocol)
+		}
+	}
+	code := closeErr.Code
+	if code == CloseNoStatusReceived {
+		code = CloseNormalClosure
+	}
+	err := c.WriteClose(code, "")
+	if err != nil && err != ErrorCloseSent {
+		return err
+	}
+	return closeErr
+}
+
+// readFrame 读取一个帧，remaining 是当前消息还允许的长度
+func (c *Conn) readFrame(remaining int64) (*frame, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return nil, err
+	}
+	f := &frame{
+		fin:    header[0]&finalBit != 0,
+		opcode: int(header[0] & 0x0f),
+	}
+	// 没有协商扩展，RSV 位必须是 0
+	if header[0]&rsvBits != 0 {
+		return nil, ErrorProtocol
+	}
+	masked := header[1]&maskBit != 0
+	// 客户端发送的帧必须掩码，服务端发送的帧不能掩码
+	if masked != c.isServer {
+		return nil, ErrorProtocol
+	}
+
+	length := int64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return nil, err
+		}
+		if ext[0]&0x80 != 0 {
+			return nil, ErrorProtocol
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	switch f.opcode {
+	case CloseMessage, PingMessage, PongMessage:
+		// 控制帧不能分片，负载不超过 125 字节
+		if !f.fin || length > maxControlPayload {
+			return nil, ErrorProtocol
+		}
+	case continuationFrame, TextMessage, BinaryMessage:
+		if length > remaining {
+			return nil, ErrorMessageTooLarge
+		}
+	default:
+		return nil, ErrorProtocol
+	}
+
+	var maskKey [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, maskKey[:]); err != nil {
+			return nil, err
+		}
+	}
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, f.payload); err != nil {
+		return nil, err
+	}
+	if masked {
+		maskBytes(maskKey, f.payload)
+	}
+	return f, nil
+}
+
+// WriteMessage 发送一条文本或者二进制消息，配置了 FragmentSize 时按大小分片发送
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return ErrorInvalidMessageType
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrorCloseSent
+	}
+
+	opcode := messageType
+	for {
+		chunk := data
+		if c.fragmentSize > 0 && len(chunk) > c.fragmentSize {
+			chunk = data[:c.fragmentSize]
+		}
+		data = data[len(chunk):]
+		fin := len(data) == 0
+		if err := c.writeFrame(fin, opcode, chunk); err != nil {
+			return err
+		}
+		if fin {
+			return c.bw.Flush()
+		}
+		opcode = continuationFrame
+	}
+}
+
+// WriteControl 发送 ping、pong 或者关闭帧，负载不能超过 125 字节
+func (c *Conn) WriteControl(messageType int, data []byte) error {
+	if messageType != PingMessage && messageType != PongMessage && messageType != CloseMessage {
+		return ErrorInvalidMessageType
+	}
+	if len(data) > maxControlPayload {
+		return ErrorProtocol
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrorCloseSent
+	}
+	if messageType == CloseMessage {
+		c.closeSent = true
+		_ = c.conn.SetWriteDeadline(time.Now().Add(closeWriteTimeout))
+	}
+	if err := c.writeFrame(true, messageType, data); err != nil {
+		return err
+	}
+	return c.bw.Flush()
+}
+
+// WriteClose 发送关闭帧发起关闭握手，之后不能再发送消息，对方回复的关闭帧由 ReadMessage 返回
+func (c *Conn) WriteClose(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	return c.WriteControl(CloseMessage, payload)
+}
+
+// Close 关闭底层连接，没有发送过关闭帧时先发送 1000 关闭帧
+func (c *Conn) Close() error {
+	_ = c.WriteClose(CloseNormalClosure, "")
+	return c.conn.Close()
+}
+
+// writeFrame 写一个帧到缓冲区，调用方需要持有 writeMu
+func (c *Conn) writeFrame(fin bool, opcode int, payload []byte) error {
+	header := make([]byte, 0, 14)
+	b0 := byte(opcode)
+	if fin {
+		b0 |= finalBit
+	}
+	header = append(header, b0)
+
+	var b1 byte
+	if !c.isServer {
+		b1 = maskBit
+	}
+	length := len(payload)
+	switch {
+	case length <= 125:
+		header = append(header, b1|byte(length))
+	case length <= 0xffff:
+		header = append(header, b1|126, byte(length>>8), byte(length))
+	default:
+		header = append(header, b1|127)
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	if !c.isServer {
+		var maskKey [4]byte
+		if _, err := io.ReadFull(rand.Reader, maskKey[:]); err != nil {
+			return err
+		}
+		header = append(header, maskKey[:]...)
+		masked := make([]byte, length)
+		copy(masked, payload)
+		maskBytes(maskKey, masked)
+		payload = masked
+	}
+
+	if _, err := c.bw.Write(header); err != nil {
+		return err
+	}
+	_, err := c.bw.Write(payload)
+	return err
+}
+
+func maskBytes(key [4]byte, data []byte) {
+	for i := range data {
+		data[i] ^= key[i&3]
+	}
+}
@@ -0,0 +1,83 @@
+package websocket
+
+import "sync"
+
+type hubMessage struct {
+	messageType int
+	data        []byte
+}
+
+// Hub 广播中心，每个连接有独立的发送队列和发送协程，慢客户端不会阻塞广播
+type Hub struct {
+	mu      sync.RWMutex
+	clients map[*Conn]chan hubMessage
+	// 每个连接发送队列长度，队列满说明客户端太慢，会被断开
+	queueSize int
+}
+
+// NewHub 创建广播中心，queueSize 是每个连接的发送队列长度
+func NewHub(queueSize int) *Hub {
+	if queueSize <= 0 {
+		queueSize = 16
+	}
+	return &Hub{
+		clients:   make(map[*Conn]chan hubMessage),
+		queueSize: queueSize,
+	}
+}
+
+// Register 加入连接，并启动该连接的发送协程
+func (h *Hub) Register(conn *Conn) {
+	queue := make(chan hubMessage, h.queueSize)
+	h.mu.Lock()
+	h.clients[conn] = queue
+	h.mu.Unlock()
+
+	go func() {
+		for msg := range queue {
+			if err := conn.WriteMessage(msg.messageType, msg.data); err != nil {
+				h.Unregister(conn)
+				conn.Close()
+				return
+			}
+		}
+	}()
+}
+
+// Unregister 移除连接，停止发送协程，不会关闭连接
+func (h *Hub) Unregister(conn *Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if queue, ok := h.clients[conn]; ok {
+		delete(h.clients, conn)
+		close(queue)
+	}
+}
+
+// Broadcast 发送消息给所有连接，发送队列已满的连接会被移除并关闭
+func (h *Hub) Broadcast(messageType int, data []byte) {
+	msg := hubMessage{messageType: messageType, data: data}
+	var slow []*Conn
+
+	h.mu.RLock()
+	for conn, queue := range h.clients {
+		select {
+		case queue <- msg:
+		default:
+			slow = append(slow, conn)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, conn := range slow {
+		h.Unregister(conn)
+		conn.Close()
+	}
+}
+
+// Len 当前连接数
+func (h *Hub) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
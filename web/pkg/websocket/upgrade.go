@@ -0,0 +1,147 @@
+package websocket
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// acceptGUID RFC 6455 规定的计算 Sec-WebSocket-Accept 的 GUID
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var ErrorBadHandshake = errors.New("websocket: bad handshake")
+var ErrorOriginNotAllowed = errors.New("websocket: origin not allowed")
+var ErrorHijackUnsupported = errors.New("websocket: response does not implement http.Hijacker")
+
+// Options 连接配置
+type Options struct {
+	// Subprotocols 服务端支持的子协议，按优先级排序；客户端为请求的子协议
+	Subprotocols []string
+	// CheckOrigin 校验 Origin，默认只允许没有 Origin 或者和 Host 相同的请求
+	CheckOrigin func(r *http.Request) bool
+	// MaxMessageSize 单条消息最大长度，默认 1MB
+	MaxMessageSize int64
+	// FragmentSize 发送消息时的分片大小，0 表示不分片
+	FragmentSize int
+}
+
+// Upgrade 把 HTTP 连接升级为 WebSocket 连接，握手失败时写入错误响应并返回错误
+func Upgrade(w http.ResponseWriter, r *http.Request, options Options) (*Conn, error) {
+	if r.Method != http.MethodGet ||
+		!headerContainsToken(r.Header, "Connection", "upgrade") ||
+		!headerContainsToken(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "Bad Request: not a websocket handshake", http.StatusBadRequest)
+		return nil, ErrorBadHandshake
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Upgrade Required: unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, ErrorBadHandshake
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "Bad Request: invalid Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, ErrorBadHandshake
+	}
+	checkOrigin := options.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		http.Error(w, "Forbidden: origin not allowed", http.StatusForbidden)
+		return nil, ErrorOriginNotAllowed
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, ErrorHijackUnsupported
+	}
+	subprotocol := selectSubprotocol(r, options.Subprotocols)
+	netConn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n"
+	if subprotocol != "" {
+		response += "Sec-WebSocket-Protocol: " + subprotocol + "\r\n"
+	}
+	response += "\r\n"
+	if _, err = brw.WriteString(response); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	if err = brw.Flush(); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	conn := newConn(netConn, brw.Reader, brw.Writer, true, options)
+	conn.subprotocol = subprotocol
+	return conn, nil
+}
+
+// IsWebSocketUpgrade 判断请求是否是 WebSocket 握手请求
+func IsWebSocketUpgrade(r *http.Request) bool {
+	return headerContainsToken(r.Header, "Connection", "upgrade") &&
+		headerContainsToken(r.Header, "Upgrade", "websocket")
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// selectSubprotocol 按服务端优先级选择客户端也支持的子协议
+func selectSubprotocol(r *http.Request, supported []string) string {
+	requested := headerTokens(r.Header, "Sec-WebSocket-Protocol")
+	for _, s := range supported {
+		for _, p := range requested {
+			if s == p {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+func headerTokens(header http.Header, name string) []string {
+	var tokens []string
+	for _, value := range header.Values(name) {
+		for _, token := range strings.Split(value, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
+
+func headerContainsToken(header http.Header, name string, token string) bool {
+	for _, t := range headerTokens(header, name) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package websocket
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newServer(t *testing.T, options Options, handle func(conn *Conn)) (*httptest.Server, string) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r, options)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handle(conn)
+	}))
+	return server, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func echo(conn *Conn) {
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if err = conn.WriteMessage(messageType, data); err != nil {
+			return
+		}
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	// RFC 6455 1.3 示例
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", acceptKey("dGhlIHNhbXBsZSBub25jZQ=="))
+}
+
+func TestConn_Echo(t *testing.T) {
+	server, url := newServer(t, Options{Subprotocols: []string{"chat"}, FragmentSize: 10}, echo)
+	defer server.Close()
+
+	// 客户端分片发送，服务端分片回复
+	conn, resp, err := Dial(url, nil, Options{Subprotocols: []string{"superchat", "chat"}, FragmentSize: 3})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	assert.Equal(t, "chat", conn.Subprotocol())
+	defer conn.Close()
+
+	messages := []struct {
+		messageType int
+		data        []byte
+	}{
+		{TextMessage, []byte("hello websocket")},
+		{BinaryMessage, []byte{0, 1, 2, 3}},
+		{TextMessage, []byte(strings.Repeat("a", 70000))},
+		{TextMessage, []byte{}},
+	}
+	for _, m := range messages {
+		assert.Nil(t, conn.WriteMessage(m.messageType, m.data))
+		messageType, data, err := conn.ReadMessage()
+		assert.Nil(t, err)
+		assert.Equal(t, m.messageType, messageType)
+		assert.True(t, bytes.Equal(m.data, data))
+	}
+}
+
+func TestConn_PingPong(t *testing.T) {
+	server, url := newServer(t, Options{}, echo)
+	defer server.Close()
+
+	conn, _, err := Dial(url, nil, Options{})
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	pong := make(chan string, 1)
+	conn.SetPongHandler(func(data []byte) error {
+		pong <- string(data)
+		return nil
+	})
+	assert.Nil(t, conn.WriteControl(PingMessage, []byte("ping")))
+	// pong 在 ReadMessage 里处理，发一条消息推动读取
+	assert.Nil(t, conn.WriteMessage(TextMessage, []byte("after ping")))
+	_, data, err := conn.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, "after ping", string(data))
+	assert.Equal(t, "ping", <-pong)
+}
+
+func TestConn_CloseHandshake(t *testing.T) {
+	serverErr := make(chan error, 1)
+	server, url := newServer(t, Options{}, func(conn *Conn) {
+		_, _, err := conn.ReadMessage()
+		serverErr <- err
+	})
+	defer server.Close()
+
+	conn, _, err := Dial(url, nil, Options{})
+	assert.Nil(t, err)
+	assert.Nil(t, conn.WriteClose(CloseGoingAway, "bye"))
+	assert.Equal(t, ErrorCloseSent, conn.WriteMessage(TextMessage, []byte("late")))
+
+	// 服务端收到关闭帧并回复
+	err = <-serverErr
+	assert.Equal(t, &CloseError{Code: CloseGoingAway, Text: "bye"}, err)
+	// 服务端回复的关闭帧带回同样的状态码
+	_, _, err = conn.ReadMessage()
+	assert.Equal(t, CloseGoingAway, err.(*CloseError).Code)
+	conn.Close()
+}
+
+func TestConn_MessageTooLarge(t *testing.T) {
+	serverErr := make(chan error, 1)
+	server, url := newServer(t, Options{MaxMessageSize: 8}, func(conn *Conn) {
+		_, _, err := conn.ReadMessage()
+		serverErr <- err
+	})
+	defer server.Close()
+
+	conn, _, err := Dial(url, nil, Options{FragmentSize: 4})
+	assert.Nil(t, err)
+	defer conn.Close()
+	assert.Nil(t, conn.WriteMessage(BinaryMessage, make([]byte, 16)))
+	assert.Equal(t, ErrorMessageTooLarge, <-serverErr)
+
+	_, _, err = conn.ReadMessage()
+	assert.Equal(t, CloseMessageTooBig, err.(*CloseError).Code)
+}
+
+func TestUpgrade_BadHandshake(t *testing.T) {
+	server, url := newServer(t, Options{}, echo)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	_, resp, err = Dial(url, http.Header{"Origin": {"http://evil.com"}}, Options{})
+	assert.Equal(t, ErrorBadHandshake, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestHub_Broadcast(t *testing.T) {
+	hub := NewHub(4)
+	server, url := newServer(t, Options{}, func(conn *Conn) {
+		hub.Register(conn)
+		defer hub.Unregister(conn)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			hub.Broadcast(TextMessage, data)
+		}
+	})
+	defer server.Close()
+
+	clients := make([]*Conn, 3)
+	for i := range clients {
+		conn, _, err := Dial(url, nil, Options{})
+		assert.Nil(t, err)
+		defer conn.Close()
+		clients[i] = conn
+	}
+	for hub.Len() < len(clients) {
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.Nil(t, clients[0].WriteMessage(TextMessage, []byte("hi all")))
+	for _, conn := range clients {
+		_, data, err := conn.ReadMessage()
+		assert.Nil(t, err)
+		assert.Equal(t, "hi all", string(data))
+	}
+}